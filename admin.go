@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/search"
+	"google.golang.org/appengine/taskqueue"
+)
+
+// recountHandler recalculates the gif and subscriber counts of every pack, and gives packs created before
+// visibilities and creation times were introduced an explicit visibility and creation time so that they show up in the
+// pack directory. Their creation time is unknown, so it is left as the zero time, which sorts them after every newer
+// pack. It is only meant to be run by administrators after deploying, so access to it should be restricted in app.yaml.
+func recountHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+
+	var packs []Pack
+	keys, err := datastore.NewQuery(packKind).GetAll(ctx, &packs)
+	if err != nil {
+		log.Errorf(ctx, "%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	index, err := search.Open(gifsIndex)
+	if err != nil {
+		log.Errorf(ctx, "%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	backfilled := 0
+	for i := range packs {
+		packName := keys[i].StringID()
+
+		subscribers, err := datastore.NewQuery(subscriptionKind).Filter("Pack =", packName).Count(ctx)
+		if err != nil {
+			log.Errorf(ctx, "%v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		gifs := 0
+		for t := index.Search(ctx, "Pack = "+packName, &search.SearchOptions{IDsOnly: true}); ; {
+			_, err := t.Next(nil)
+			if err == search.Done {
+				break
+			}
+			if err != nil {
+				log.Errorf(ctx, "%v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			gifs++
+		}
+
+		// packs created before creation times were recorded have no Created property, and datastore leaves entities
+		// without a property out of queries sorted by it. Putting the pack back stores the zero time.
+		if packs[i].Created.IsZero() {
+			backfilled++
+		}
+
+		packs[i].Visibility = packVisibility(packs[i])
+		packs[i].Gifs = gifs
+		packs[i].Subscribers = subscribers
+	}
+
+	_, err = datastore.PutMulti(ctx, keys, packs)
+	if err != nil {
+		log.Errorf(ctx, "%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintf(w, "Recounted %d packs and backfilled the creation time of %d\n", len(packs), backfilled)
+}
+
+// adminBatchSize is the number of gifs each task of a long running admin job handles, which is also the most documents
+// the search API will put in one call
+const adminBatchSize = 200
+
+// listGifBatch returns up to adminBatchSize gifs from the gifs index and their ids, starting at the gif with id
+// startID, or the first gif if startID is empty. next is the id to start the following batch at, and is empty once
+// every gif has been listed.
+func listGifBatch(ctx context.Context, index *search.Index, startID string) (ids []string, gifs []Gif, next string, err error) {
+	for t := index.List(ctx, &search.ListOptions{StartID: startID, Limit: adminBatchSize + 1}); ; {
+		var gif Gif
+		id, err := t.Next(&gif)
+		if err == search.Done {
+			break
+		}
+		if err != nil {
+			return nil, nil, "", err
+		}
+
+		// the extra gif is only listed to find out where the next batch starts
+		if len(ids) == adminBatchSize {
+			next = id
+			break
+		}
+
+		ids = append(ids, id)
+		gifs = append(gifs, gif)
+	}
+
+	return ids, gifs, next, nil
+}
+
+// continueAdminJob queues a task which runs the admin handler at path again from the gif with id next, if there is one.
+func continueAdminJob(ctx context.Context, path, next string) error {
+	if next == "" {
+		return nil
+	}
+
+	_, err := taskqueue.Add(ctx, taskqueue.NewPOSTTask(path, url.Values{"start": {next}}), "")
+	return err
+}
+
+// rekeyHandler stores gifs saved before file unique ids were recorded under their file unique ids, which are looked up
+// with getFile. Their file ids are kept for sending. Gifs which turn out to be duplicates of a gif already in the same
+// pack are removed, and their keywords are added to the gif they duplicate. Each request handles one batch of gifs
+// starting at the start parameter and queues a task for the next batch. It is only meant to be run by administrators
+// after deploying, so access to it should be restricted in app.yaml.
+func rekeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	bot := newBot(ctx)
+
+	index, err := search.Open(gifsIndex)
+	if err != nil {
+		log.Errorf(ctx, "%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ids, gifs, next, err := listGifBatch(ctx, index, r.FormValue("start"))
+	if err != nil {
+		log.Errorf(ctx, "%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var putIDs, deleteIDs []string
+	var putGifs []interface{}
+	pending := make(map[string]*Gif)
+	removed := make(map[string]int)
+	var rekeyed, duplicates, skipped int
+	for i, gif := range gifs {
+		if gif.FileUniqueID != "" {
+			continue
+		}
+
+		resp, err := bot.MakeRequest("getFile", url.Values{"file_id": {string(gif.FileID)}})
+		if err != nil {
+			log.Warningf(ctx, "could not get file %s: %v", gif.FileID, err)
+			skipped++
+			continue
+		}
+
+		var file struct {
+			FileUniqueID string `json:"file_unique_id"`
+		}
+		err = json.Unmarshal(resp.Result, &file)
+		if err != nil || file.FileUniqueID == "" {
+			log.Warningf(ctx, "no file unique id for file %s: %v", gif.FileID, err)
+			skipped++
+			continue
+		}
+
+		gif := gif
+		gif.FileUniqueID = search.Atom(file.FileUniqueID)
+		packName := string(gif.Pack)
+		id := gifKey(packName, file.FileUniqueID)
+		deleteIDs = append(deleteIDs, ids[i])
+
+		// the same gif may already be in this pack with a different file id, either saved earlier or earlier in this batch
+		existing, ok := pending[id]
+		if !ok {
+			saved, err := GetGif(ctx, packName, file.FileUniqueID)
+			if err != nil && err != ErrNotFound {
+				log.Errorf(ctx, "%v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if err == nil {
+				existing = &saved
+				pending[id] = existing
+				putIDs = append(putIDs, id)
+				putGifs = append(putGifs, existing)
+			}
+		}
+
+		if existing != nil {
+			existing.Keywords = mergeKeywords(existing.Keywords, gif.Keywords)
+			existing.Terms = keywordTerms(existing.Keywords)
+			removed[strings.ToUpper(packName)]++
+			duplicates++
+			continue
+		}
+
+		pending[id] = &gif
+		putIDs = append(putIDs, id)
+		putGifs = append(putGifs, &gif)
+		rekeyed++
+	}
+
+	// put the rekeyed gifs before deleting the old ones, so that no gifs are lost if a request fails
+	if len(putIDs) > 0 {
+		_, err = index.PutMulti(ctx, putIDs, putGifs)
+		if err == nil {
+			err = index.DeleteMulti(ctx, deleteIDs)
+		}
+		if err != nil {
+			log.Errorf(ctx, "%v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for packName, n := range removed {
+		err := updatePackCounts(ctx, packName, -n, 0)
+		if err != nil {
+			log.Errorf(ctx, "%v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = continueAdminJob(ctx, "/admin/rekey", next)
+	if err != nil {
+		log.Errorf(ctx, "%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof(ctx, "rekeyed %d gifs, removed %d duplicates and skipped %d gifs", rekeyed, duplicates, skipped)
+	fmt.Fprintf(w, "Rekeyed %d gifs, removed %d duplicates and skipped %d gifs\n", rekeyed, duplicates, skipped)
+	if next != "" {
+		fmt.Fprintf(w, "Queued the next batch starting at %s\n", next)
+	}
+}
+
+// reindexHandler recalculates the normalised search terms of every gif from its keywords and title. It needs to be run after
+// deploying keyword normalisation and after changing the keyword normalisation options, since gifs are only found by
+// the terms they were stored with. Each request handles one batch of gifs starting at the start parameter and queues a
+// task for the next batch. It is only meant to be run by administrators, so access to it should be restricted in
+// app.yaml.
+func reindexHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+
+	index, err := search.Open(gifsIndex)
+	if err != nil {
+		log.Errorf(ctx, "%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ids, gifs, next, err := listGifBatch(ctx, index, r.FormValue("start"))
+	if err != nil {
+		log.Errorf(ctx, "%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var putIDs []string
+	var putGifs []interface{}
+	for i := range gifs {
+		terms, titleTerms := keywordTerms(gifs[i].Keywords), keywordTerms(gifs[i].Title)
+		if gifs[i].Terms != terms || gifs[i].TitleTerms != titleTerms {
+			gifs[i].Terms = terms
+			gifs[i].TitleTerms = titleTerms
+			putIDs = append(putIDs, ids[i])
+			putGifs = append(putGifs, &gifs[i])
+		}
+	}
+
+	if len(putIDs) > 0 {
+		_, err = index.PutMulti(ctx, putIDs, putGifs)
+		if err != nil {
+			log.Errorf(ctx, "%v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = continueAdminJob(ctx, "/admin/reindex", next)
+	if err != nil {
+		log.Errorf(ctx, "%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof(ctx, "reindexed %d gifs", len(putIDs))
+	fmt.Fprintf(w, "Reindexed %d gifs\n", len(putIDs))
+	if next != "" {
+		fmt.Fprintf(w, "Queued the next batch starting at %s\n", next)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// app engine datastore kinds
+const (
+	auditEntryKind = "AuditEntry"
+)
+
+// audited actions
+const (
+	auditTransferRequested = "transfer requested"
+	auditTransferAccepted  = "transfer accepted"
+	auditTransferCancelled = "transfer cancelled"
+)
+
+// AuditEntry represents a change to a gif pack in datastore. Actor is the user who made the change and Subject is the
+// user it was made to, if any.
+type AuditEntry struct {
+	Pack    string
+	Action  string
+	Actor   int
+	Subject int
+	Time    time.Time
+}
+
+// addAuditEntry records that actor performed action to subject in pack.
+func addAuditEntry(ctx context.Context, packName, action string, actor, subject int) error {
+	entry := AuditEntry{
+		Pack:    strings.ToUpper(packName),
+		Action:  action,
+		Actor:   actor,
+		Subject: subject,
+		Time:    time.Now(),
+	}
+
+	key := datastore.NewIncompleteKey(ctx, auditEntryKind, nil)
+	_, err := datastore.Put(ctx, key, &entry)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetAuditTrail returns the recorded changes to pack from oldest to newest. Only users who can manage the members of
+// pack can see its audit trail.
+func GetAuditTrail(ctx context.Context, packName string, userID int) ([]AuditEntry, error) {
+	// validate pack name
+	if !packNameRegex.MatchString(packName) {
+		return nil, ErrInvalidName
+	}
+
+	// normalise pack name
+	packName = strings.ToUpper(packName)
+
+	pack, err := GetPack(ctx, packName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !Can(pack, userID, ActionManageMembers) {
+		return nil, ErrNotAllowed
+	}
+
+	var entries []AuditEntry
+	q := datastore.NewQuery(auditEntryKind).Filter("Pack =", packName)
+	_, err = q.GetAll(ctx, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	// sort in memory to avoid needing a composite index
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return entries, nil
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/log"
+)
+
+// callbackDataSeparator separates the fields of callback data. Callback data arguments must not contain it.
+const callbackDataSeparator = ":"
+
+// callback errors
+var (
+	ErrInvalidCallbackData = errors.New("invalid callback data")
+	ErrCallbackTooLong     = errors.New("callback data too long")
+)
+
+// CallbackData represents the data attached to an inline keyboard button. It is encoded as
+//
+//	<namespace>:<version>[:<arg>]*
+//
+// The namespace identifies the feature a button belongs to, and the version is bumped whenever the meaning of the
+// arguments changes so that buttons on old messages are not misinterpreted.
+type CallbackData struct {
+	Namespace string
+	Version   int
+	Args      []string
+}
+
+// NewCallbackData is a convenience function for creating a CallbackData.
+func NewCallbackData(namespace string, version int, args ...string) CallbackData {
+	return CallbackData{
+		Namespace: namespace,
+		Version:   version,
+		Args:      args,
+	}
+}
+
+func (data CallbackData) String() string {
+	fields := append([]string{data.Namespace, strconv.Itoa(data.Version)}, data.Args...)
+	return strings.Join(fields, callbackDataSeparator)
+}
+
+// ParseCallbackData parses the data attached to an inline keyboard button.
+func ParseCallbackData(s string) (CallbackData, error) {
+	fields := strings.Split(s, callbackDataSeparator)
+	if len(fields) < 2 || fields[0] == "" {
+		return CallbackData{}, ErrInvalidCallbackData
+	}
+
+	version, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return CallbackData{}, ErrInvalidCallbackData
+	}
+
+	data := CallbackData{
+		Namespace: fields[0],
+		Version:   version,
+		Args:      fields[2:],
+	}
+
+	return data, nil
+}
+
+// NewCallbackButton returns an inline keyboard button which sends data when pressed. err will be ErrCallbackTooLong if
+// data does not fit in a button.
+func NewCallbackButton(text string, data CallbackData) (tgbotapi.InlineKeyboardButton, error) {
+	s := data.String()
+	if len(s) > maxCallbackDataLength {
+		return tgbotapi.InlineKeyboardButton{}, ErrCallbackTooLong
+	}
+
+	return tgbotapi.NewInlineKeyboardButtonData(text, s), nil
+}
+
+// CallbackResponse represents how a callback query should be answered. If Text is set, the message the button was
+// attached to is edited in place to show Text and ReplyMarkup. If only ReplyMarkup is set, just the buttons are
+// replaced.
+type CallbackResponse struct {
+	Notification string
+	ShowAlert    bool
+	Text         string
+	ReplyMarkup  *tgbotapi.InlineKeyboardMarkup
+}
+
+// A CallbackHandler handles a callback query with data in the namespace and version it was registered for.
+type CallbackHandler func(ctx context.Context, bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data CallbackData) (CallbackResponse, error)
+
+// callbackHandlers associates callback data namespaces and versions with their handlers
+var callbackHandlers = map[string]map[int]CallbackHandler{
+	"browse": {
+		1: browseCallbackHandler,
+	},
+	"subscribe": {
+		1: subscribeCallbackHandler,
+	},
+	"pack": {
+		1: packCallbackHandler,
+	},
+	"quickadd": {
+		1: quickAddCallbackHandler,
+	},
+	"listgifs": {
+		1: listGifsCallbackHandler,
+	},
+	"gif": {
+		1: gifCallbackHandler,
+	},
+}
+
+// HandleCallbackQuery routes incoming callback queries from inline keyboard buttons to their handlers. Every callback
+// query is answered, even if it could not be handled, so that the button stops showing a loading indicator.
+func HandleCallbackQuery(ctx context.Context, bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery) {
+	response, err := routeCallbackQuery(ctx, bot, callbackQuery)
+	if err != nil {
+		log.Errorf(ctx, "%v", err)
+		response = CallbackResponse{
+			Notification: fmt.Sprintf("Oh no! Something went wrong. Request Id: %s", appengine.RequestID(ctx)),
+			ShowAlert:    true,
+		}
+	}
+
+	if response.Text != "" || response.ReplyMarkup != nil {
+		err := editCallbackMessage(bot, callbackQuery, response)
+		if err != nil {
+			log.Errorf(ctx, "%v", err)
+		}
+	}
+
+	config := tgbotapi.CallbackConfig{
+		CallbackQueryID: callbackQuery.ID,
+		Text:            response.Notification,
+		ShowAlert:       response.ShowAlert,
+	}
+
+	_, err = bot.AnswerCallbackQuery(config)
+	if err != nil {
+		log.Errorf(ctx, "%v", err)
+	}
+}
+
+func routeCallbackQuery(ctx context.Context, bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery) (CallbackResponse, error) {
+	data, err := ParseCallbackData(callbackQuery.Data)
+	if err != nil {
+		return expiredCallbackResponse(), nil
+	}
+
+	handler := callbackHandlers[data.Namespace][data.Version]
+	if handler == nil {
+		return expiredCallbackResponse(), nil
+	}
+
+	return handler(ctx, bot, callbackQuery, data)
+}
+
+// expiredCallbackResponse is the response to buttons which are no longer understood, usually because they are on an old
+// message.
+func expiredCallbackResponse() CallbackResponse {
+	return CallbackResponse{
+		Notification: "Oops, this button has expired. Please run the command again.",
+		ShowAlert:    true,
+	}
+}
+
+// editCallbackMessage edits the message a callback query came from according to response.
+func editCallbackMessage(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, response CallbackResponse) error {
+	var base tgbotapi.BaseEdit
+	if message := callbackQuery.Message; message != nil {
+		base.ChatID = message.Chat.ID
+		base.MessageID = message.MessageID
+	} else {
+		base.InlineMessageID = callbackQuery.InlineMessageID
+	}
+	base.ReplyMarkup = response.ReplyMarkup
+
+	var edit tgbotapi.Chattable
+	if response.Text != "" {
+		edit = tgbotapi.EditMessageTextConfig{
+			BaseEdit: base,
+			Text:     response.Text,
+		}
+	} else {
+		edit = tgbotapi.EditMessageReplyMarkupConfig{
+			BaseEdit: base,
+		}
+	}
+
+	_, err := bot.Send(edit)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func browseCallbackHandler(ctx context.Context, bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data CallbackData) (CallbackResponse, error) {
+	if len(data.Args) != 2 {
+		return CallbackResponse{}, ErrInvalidCallbackData
+	}
+
+	sortBy := data.Args[0]
+	page, err := strconv.Atoi(data.Args[1])
+	if err != nil {
+		return CallbackResponse{}, ErrInvalidCallbackData
+	}
+
+	packs, more, err := BrowsePacks(ctx, sortBy, page)
+	if err != nil {
+		return CallbackResponse{}, err
+	}
+
+	text, markup := browseMessage(packs, sortBy, page, more)
+	response := CallbackResponse{
+		Text:        text,
+		ReplyMarkup: &markup,
+	}
+
+	return response, nil
+}
+
+func subscribeCallbackHandler(ctx context.Context, bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data CallbackData) (CallbackResponse, error) {
+	if len(data.Args) != 1 {
+		return CallbackResponse{}, ErrInvalidCallbackData
+	}
+
+	packName := data.Args[0]
+	subscribed, err := Subscribe(ctx, packName, callbackQuery.From.ID)
+	if err != nil {
+		switch err {
+		case ErrNotFound, ErrDeleted:
+			return CallbackResponse{Notification: "Oops! That gif pack no longer exists."}, nil
+		default:
+			return CallbackResponse{}, err
+		}
+	}
+
+	if subscribed {
+		return CallbackResponse{Notification: fmt.Sprintf("Great! You have been subscribed to %s!", packName)}, nil
+	}
+
+	return CallbackResponse{Notification: fmt.Sprintf("Don't worry, you are already subscribed to %s!", packName)}, nil
+}
+
+// packCallbackHandler handles the buttons on the /mypacks panel. Its arguments are an action followed by a pack name,
+// except for the list action which has no pack name.
+func packCallbackHandler(ctx context.Context, bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data CallbackData) (CallbackResponse, error) {
+	userID := callbackQuery.From.ID
+	if len(data.Args) == 0 {
+		return CallbackResponse{}, ErrInvalidCallbackData
+	}
+
+	action := data.Args[0]
+	if action == "list" {
+		userPacks, err := GetUserPacks(ctx, userID)
+		if err != nil {
+			return CallbackResponse{}, err
+		}
+
+		text, markup := myPacksMessage(userPacks)
+		return CallbackResponse{Text: text, ReplyMarkup: &markup}, nil
+	}
+
+	if len(data.Args) != 2 {
+		return CallbackResponse{}, ErrInvalidCallbackData
+	}
+
+	pack, err := GetPack(ctx, data.Args[1])
+	if err != nil {
+		switch err {
+		case ErrInvalidName, ErrNotFound, ErrDeleted:
+			back := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(myPacksBackButton()))
+			return CallbackResponse{Text: "Whoops, that gif pack no longer exists.", ReplyMarkup: &back}, nil
+		default:
+			return CallbackResponse{}, err
+		}
+	}
+
+	switch action {
+	case "open":
+		if PackRole(pack, userID) == RoleNone {
+			return CallbackResponse{Notification: "Oops, it seems like you are not a member of this pack anymore."}, nil
+		}
+
+		text, markup := packPanel(pack, userID)
+		return CallbackResponse{Text: text, ReplyMarkup: &markup}, nil
+	case "members":
+		if PackRole(pack, userID) == RoleNone {
+			return CallbackResponse{Notification: "Oops, it seems like you are not a member of this pack. Only pack members can see who else is in it."}, nil
+		}
+
+		markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(packBackButton(pack)))
+		return CallbackResponse{Text: membersText(pack), ReplyMarkup: &markup}, nil
+	case "share":
+		if PackRole(pack, userID) == RoleNone {
+			return CallbackResponse{Notification: "Oops, it seems like you are not a member of this pack anymore."}, nil
+		}
+
+		text, markup := shareMessage(pack)
+		return CallbackResponse{Text: text, ReplyMarkup: &markup}, nil
+	case "newgif":
+		if !Can(pack, userID, ActionAddGif) {
+			return CallbackResponse{Notification: "Oops, it seems like you are not allowed to add gifs to this pack."}, nil
+		}
+
+		state := ConversationState{
+			State: stateNewGifWaitGif,
+			Data: map[string]string{
+				"packName": pack.Name,
+			},
+		}
+
+		err := startCallbackConversation(ctx, bot, callbackQuery, state, fmt.Sprintf("Please send me the gif you want to add to %s.", pack.Name))
+		return CallbackResponse{}, err
+	case "deletegif":
+		if !Can(pack, userID, ActionDeleteGif) {
+			return CallbackResponse{Notification: "Oops, it seems like you are not allowed to delete gifs from this pack."}, nil
+		}
+
+		state := ConversationState{
+			State: stateDeleteGifWaitGif,
+			Data: map[string]string{
+				"packName": pack.Name,
+			},
+		}
+
+		err := startCallbackConversation(ctx, bot, callbackQuery, state, fmt.Sprintf("Please send me the gif you want to delete from %s.", pack.Name))
+		return CallbackResponse{}, err
+	case "delete":
+		if !Can(pack, userID, ActionDeletePack) {
+			return CallbackResponse{Notification: "Oops, only the pack owner can delete this pack."}, nil
+		}
+
+		text, markup := confirmDeleteMessage(pack)
+		return CallbackResponse{Text: text, ReplyMarkup: &markup}, nil
+	case "confirmdelete":
+		err := SoftDeletePack(ctx, pack.Name, userID)
+		if err != nil {
+			if err == ErrNotAllowed {
+				return CallbackResponse{Notification: "Oops, only the pack owner can delete this pack."}, nil
+			}
+
+			return CallbackResponse{}, err
+		}
+
+		markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(myPacksBackButton()))
+		return CallbackResponse{Text: fmt.Sprintf("Great! %s has been deleted.", pack.Name), ReplyMarkup: &markup}, nil
+	}
+
+	return CallbackResponse{}, ErrInvalidCallbackData
+}
+
+// quickAddCallbackHandler adds the gif a user sent without a caption to the pack they picked, asking them for its
+// keywords next.
+func quickAddCallbackHandler(ctx context.Context, bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data CallbackData) (CallbackResponse, error) {
+	if len(data.Args) != 1 || callbackQuery.Message == nil {
+		return CallbackResponse{}, ErrInvalidCallbackData
+	}
+
+	userID := callbackQuery.From.ID
+	chatID := callbackQuery.Message.Chat.ID
+
+	// the gif is kept in the conversation state rather than the button, which has no room for its file id
+	state, err := GetConversationState(ctx, chatID, userID)
+	if err != nil {
+		return CallbackResponse{}, err
+	}
+
+	if state.State != stateQuickAddWaitPack {
+		return expiredCallbackResponse(), nil
+	}
+
+	pack, err := GetPack(ctx, data.Args[0])
+	if err != nil {
+		switch err {
+		case ErrInvalidName, ErrNotFound, ErrDeleted:
+			return CallbackResponse{Notification: "Whoops, that gif pack no longer exists."}, nil
+		default:
+			return CallbackResponse{}, err
+		}
+	}
+
+	if !Can(pack, userID, ActionAddGif) {
+		return CallbackResponse{Notification: "Oops, it seems like you are not allowed to add gifs to this pack."}, nil
+	}
+
+	state.State = stateNewGifWaitKeywords
+	state.Data["packName"] = pack.Name
+	err = SetConversationState(ctx, chatID, userID, state)
+	if err != nil {
+		return CallbackResponse{}, err
+	}
+
+	text := fmt.Sprintf("Alright, now send me some keywords that describe this gif for %s.", pack.Name)
+	return CallbackResponse{Text: text}, nil
+}
+
+// listGifsCallbackHandler sends another page of /listgifs and removes the buttons from the page before it, so that
+// only the latest page can be turned.
+func listGifsCallbackHandler(ctx context.Context, bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data CallbackData) (CallbackResponse, error) {
+	if len(data.Args) != 2 || callbackQuery.Message == nil {
+		return CallbackResponse{}, ErrInvalidCallbackData
+	}
+
+	page, err := strconv.Atoi(data.Args[1])
+	if err != nil {
+		return CallbackResponse{}, ErrInvalidCallbackData
+	}
+
+	ok, err := sendGifsPage(ctx, bot, callbackQuery.Message.Chat.ID, callbackQuery.From.ID, data.Args[0], page)
+	if err != nil {
+		switch err {
+		case ErrInvalidName, ErrNotFound, ErrDeleted:
+			return CallbackResponse{Notification: "Whoops, that gif pack no longer exists."}, nil
+		default:
+			return CallbackResponse{}, err
+		}
+	}
+
+	if !ok {
+		return CallbackResponse{Notification: "Oops, there are no more gifs to show."}, nil
+	}
+
+	markup := noButtons()
+	return CallbackResponse{ReplyMarkup: &markup}, nil
+}
+
+// gifCallbackHandler handles the buttons on gifs sent by /listgifs. Its arguments are an action, a pack name and a gif
+// id. Gif messages have no text to edit, so only their buttons are changed.
+func gifCallbackHandler(ctx context.Context, bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data CallbackData) (CallbackResponse, error) {
+	if len(data.Args) != 3 {
+		return CallbackResponse{}, ErrInvalidCallbackData
+	}
+
+	userID := callbackQuery.From.ID
+	action, id := data.Args[0], data.Args[2]
+
+	pack, err := GetPack(ctx, data.Args[1])
+	if err != nil {
+		switch err {
+		case ErrInvalidName, ErrNotFound, ErrDeleted:
+			return CallbackResponse{Notification: "Whoops, that gif pack no longer exists."}, nil
+		default:
+			return CallbackResponse{}, err
+		}
+	}
+
+	gif, err := GetGif(ctx, pack.Name, id)
+	if err != nil {
+		if err == ErrNotFound {
+			markup := noButtons()
+			return CallbackResponse{Notification: "Whoops, that gif is no longer in this pack.", ReplyMarkup: &markup}, nil
+		}
+
+		return CallbackResponse{}, err
+	}
+
+	switch action {
+	case "edit":
+		if !Can(pack, userID, ActionEditGif) {
+			return CallbackResponse{Notification: "Oops, it seems like you are not allowed to edit gifs in this pack."}, nil
+		}
+
+		err := startCallbackConversation(ctx, bot, callbackQuery, editGifState(pack.Name, gif), editGifPrompt(gif))
+		return CallbackResponse{}, err
+	case "delete":
+		if !Can(pack, userID, ActionDeleteGif) {
+			return CallbackResponse{Notification: "Oops, it seems like you are not allowed to delete gifs from this pack."}, nil
+		}
+
+		markup := confirmDeleteGifButtons(pack, gif)
+		return CallbackResponse{Notification: "Are you sure you want to delete this gif?", ReplyMarkup: &markup}, nil
+	case "confirmdelete":
+		_, err := DeleteGif(ctx, pack.Name, userID, id)
+		if err != nil {
+			if err == ErrNotAllowed {
+				return CallbackResponse{Notification: "Oops, it seems like you are not allowed to delete gifs from this pack."}, nil
+			}
+
+			return CallbackResponse{}, err
+		}
+
+		markup := noButtons()
+		return CallbackResponse{Notification: fmt.Sprintf("Great! The gif has been deleted from %s.", pack.Name), ReplyMarkup: &markup}, nil
+	case "keep":
+		markup, ok := gifButtons(pack, gif, userID)
+		if !ok {
+			markup = noButtons()
+		}
+
+		return CallbackResponse{ReplyMarkup: &markup}, nil
+	}
+
+	return CallbackResponse{}, ErrInvalidCallbackData
+}
+
+// startCallbackConversation starts a multiple step command from a button by setting the conversation state of the user
+// who pressed it and sending them text asking for the next input.
+func startCallbackConversation(ctx context.Context, bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, state ConversationState, text string) error {
+	if callbackQuery.Message == nil {
+		return ErrInvalidCallbackData
+	}
+
+	chatID := callbackQuery.Message.Chat.ID
+	err := SetConversationState(ctx, chatID, callbackQuery.From.ID, state)
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.Send(tgbotapi.NewMessage(chatID, text))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/appengine/aetest"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestParseCallbackData(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		data, err := ParseCallbackData("browse:1:popular:2")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, data, NewCallbackData("browse", 1, "popular", "2"))
+	})
+
+	t.Run("no args", func(t *testing.T) {
+		data, err := ParseCallbackData("browse:2")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, data.Namespace, "browse")
+		assert.Equal(t, data.Version, 2)
+		assert.Len(t, data.Args, 0)
+	})
+
+	t.Run("unversioned", func(t *testing.T) {
+		_, err := ParseCallbackData("browse:popular:2")
+		assert.Equal(t, err, ErrInvalidCallbackData)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := ParseCallbackData("")
+		assert.Equal(t, err, ErrInvalidCallbackData)
+	})
+}
+
+func TestCallbackDataString(t *testing.T) {
+	t.Parallel()
+
+	data := NewCallbackData("subscribe", 1, "PACK1")
+	assert.Equal(t, data.String(), "subscribe:1:PACK1")
+
+	parsed, err := ParseCallbackData(data.String())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, parsed, data)
+}
+
+func TestNewCallbackButton(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewCallbackButton("Subscribe", NewCallbackData("subscribe", 1, "PACK1"))
+	assert.Equal(t, err, nil)
+
+	_, err = NewCallbackButton("Subscribe", NewCallbackData("subscribe", 1, strings.Repeat("A", maxCallbackDataLength)))
+	assert.Equal(t, err, ErrCallbackTooLong)
+}
+
+func TestPackCallbackHandler(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := NewContext(&aetest.Options{
+		StronglyConsistentDatastore: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	pack1 := Pack{
+		Name:       "pack1",
+		Creator:    1,
+		Visibility: VisibilityPrivate,
+	}
+
+	key := datastore.NewKey(ctx, packKind, strings.ToUpper(pack1.Name), 0, nil)
+	_, err = datastore.Put(ctx, key, &pack1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range []string{"open", "members", "share"} {
+		t.Run(action+" as a member", func(t *testing.T) {
+			callbackQuery := &tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 1}}
+			resp, err := packCallbackHandler(ctx, nil, callbackQuery, NewCallbackData("pack", 1, action, pack1.Name))
+			assert.Equal(t, err, nil)
+			assert.Equal(t, resp.Notification, "")
+			assert.NotEqual(t, resp.Text, "")
+		})
+
+		t.Run(action+" as a non-member", func(t *testing.T) {
+			callbackQuery := &tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 2}}
+			resp, err := packCallbackHandler(ctx, nil, callbackQuery, NewCallbackData("pack", 1, action, pack1.Name))
+			assert.Equal(t, err, nil)
+			assert.NotEqual(t, resp.Notification, "")
+			assert.Equal(t, resp.Text, "")
+		})
+	}
+}
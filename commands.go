@@ -2,25 +2,63 @@ package main
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/go-telegram-bot-api/telegram-bot-api"
 	"golang.org/x/net/context"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/search"
 )
 
 var commandHandlers = map[string]MessageHandler{
-	"newpack":       cmdNewPackHandler,
-	"mypacks":       cmdMyPacksHandler,
-	"deletepack":    cmdDeletePackHandler,
-	"newgif":        cmdNewGifHandler,
-	"deletegif":     cmdDeleteGifHandler,
-	"subscribe":     cmdSubscribeHandler,
-	"sub":           cmdSubscribeHandler,
-	"unsubscribe":   cmdUnsubscribeHandler,
-	"unsub":         cmdUnsubscribeHandler,
-	"subscriptions": cmdSubscriptionsHandler,
-	"mysubs":        cmdSubscriptionsHandler,
-	"version":       cmdVersionHandler,
-	"cancel":        cmdCancelHandler,
+	"start":           cmdStartHandler,
+	"newpack":         cmdNewPackHandler,
+	"mypacks":         cmdMyPacksHandler,
+	"deletepack":      cmdDeletePackHandler,
+	"newgif":          cmdNewGifHandler,
+	"newgifs":         cmdNewGifsHandler,
+	"done":            cmdDoneHandler,
+	"save":            cmdSaveHandler,
+	"copygif":         cmdCopyGifHandler,
+	"movegif":         cmdMoveGifHandler,
+	"mergepacks":      cmdMergePacksHandler,
+	"forkpack":        cmdForkPackHandler,
+	"deletegif":       cmdDeleteGifHandler,
+	"editgif":         cmdEditGifHandler,
+	"listgifs":        cmdListGifsHandler,
+	"keywords":        cmdKeywordsHandler,
+	"synonyms":        cmdSynonymsHandler,
+	"skip":            cmdSkipHandler,
+	"none":            cmdNoneHandler,
+	"subscribe":       cmdSubscribeHandler,
+	"sub":             cmdSubscribeHandler,
+	"unsubscribe":     cmdUnsubscribeHandler,
+	"unsub":           cmdUnsubscribeHandler,
+	"subscriptions":   cmdSubscriptionsHandler,
+	"mysubs":          cmdSubscriptionsHandler,
+	"invite":          cmdInviteHandler,
+	"invites":         cmdInvitesHandler,
+	"revokeinvite":    cmdRevokeInviteHandler,
+	"members":         cmdMembersHandler,
+	"setrole":         cmdSetRoleHandler,
+	"transferpack":    cmdTransferPackHandler,
+	"accepttransfer":  cmdAcceptTransferHandler,
+	"declinetransfer": cmdDeclineTransferHandler,
+	"audit":           cmdAuditHandler,
+	"setvisibility":   cmdSetVisibilityHandler,
+	"browse":          cmdBrowseHandler,
+	"findpack":        cmdFindPackHandler,
+	"version":         cmdVersionHandler,
+	"cancel":          cmdCancelHandler,
+}
+
+// statefulCommands are commands which continue the current conversation instead of starting a new one, so the
+// conversation state is not cleared before they run.
+var statefulCommands = map[string]bool{
+	"done": true,
+	"skip": true,
+	"none": true,
 }
 
 // MessageHandler represents a function which handles an incoming message.
@@ -89,35 +127,17 @@ func cmdMyPacksHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbot
 	userID := message.From.ID
 	chatID := message.Chat.ID
 
-	var text string
 	userPacks, err := GetUserPacks(ctx, userID)
 	if err != nil {
 		return err
 	}
 
-	// enumerate packs user has created
-	if len(userPacks.IsCreator) > 0 {
-		text += "Here are the gif packs you have created:\n"
-
-		for i, pack := range userPacks.IsCreator {
-			text += fmt.Sprintf("%d. %s\n", i+1, pack.Name)
-		}
-	} else {
-		text += "You have not created any gif packs.\n"
-	}
-
-	// enumerate packs user is a contributor to
-	if len(userPacks.IsContributor) > 0 {
-		text += "Here are the gif packs you are a contributor to:\n"
-
-		for i, pack := range userPacks.IsContributor {
-			text += fmt.Sprintf("%d. %s\n", i+1, pack.Name)
-		}
-	} else {
-		text += "You are not a contributor to any gif packs."
+	text, markup := myPacksMessage(userPacks)
+	reply := tgbotapi.NewMessage(chatID, text)
+	if len(markup.InlineKeyboard) > 0 {
+		reply.ReplyMarkup = markup
 	}
 
-	reply := tgbotapi.NewMessage(chatID, text)
 	_, err = bot.Send(reply)
 	if err != nil {
 		return err
@@ -190,6 +210,7 @@ func cmdUnsubscribeHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *t
 	chatID := message.Chat.ID
 
 	var text string
+	var packNames []string
 	done := false
 	if packName := message.CommandArguments(); packName != "" {
 		unsubscribed, err := Unsubscribe(ctx, packName, userID)
@@ -214,6 +235,12 @@ func cmdUnsubscribeHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *t
 			}
 		}
 	} else {
+		var err error
+		packNames, err = subscribedPackNames(ctx, userID)
+		if err != nil {
+			return err
+		}
+
 		text = "What is the name of the gif pack you want to unsubscribe from?"
 	}
 
@@ -240,6 +267,10 @@ func cmdUnsubscribeHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *t
 		}
 	}
 
+	if keyboard, ok := packNamesKeyboard(packNames); ok {
+		reply.ReplyMarkup = keyboard
+	}
+
 	_, err := bot.Send(reply)
 	if err != nil {
 		return err
@@ -278,10 +309,26 @@ func cmdSubscriptionsHandler(ctx context.Context, bot *tgbotapi.BotAPI, message
 }
 
 func cmdNewGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	return startNewGif(ctx, bot, message, false)
+}
+
+func cmdNewGifsHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	return startNewGif(ctx, bot, message, true)
+}
+
+// startNewGif starts adding gifs to a pack. In batch mode the user keeps sending gifs and keywords until they send
+// /done.
+func startNewGif(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, batch bool) error {
 	userID := message.From.ID
 	chatID := message.Chat.ID
 
+	data := make(map[string]string)
+	if batch {
+		data["batch"] = "true"
+	}
+
 	var reply tgbotapi.MessageConfig
+	var packNames []string
 	packName := message.CommandArguments()
 
 	if packName != "" {
@@ -298,12 +345,11 @@ func cmdNewGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbota
 				return err
 			}
 		} else {
-			if pack.Creator == userID {
+			if Can(pack, userID, ActionAddGif) {
+				data["packName"] = packName
 				state := ConversationState{
 					State: stateNewGifWaitGif,
-					Data: map[string]string{
-						"packName": packName,
-					},
+					Data:  data,
 				}
 
 				err := SetConversationState(ctx, chatID, userID, state)
@@ -312,10 +358,13 @@ func cmdNewGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbota
 				}
 
 				text := "Please send me the gif you want to add to this pack."
+				if batch {
+					text = "Please send me the first gif you want to add to this pack. Send /done when you are finished."
+				}
 				reply = tgbotapi.NewMessage(chatID, text)
 
 			} else {
-				text := "Oops, it seems like you are not the creator of this pack. Only the pack creator can add gifs to a pack."
+				text := "Oops, it seems like you are not allowed to add gifs to this pack."
 				reply = tgbotapi.NewMessage(chatID, text)
 			}
 		}
@@ -323,6 +372,7 @@ func cmdNewGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbota
 	} else {
 		state := ConversationState{
 			State: stateNewGifWaitPackName,
+			Data:  data,
 		}
 
 		err := SetConversationState(ctx, chatID, userID, state)
@@ -330,6 +380,11 @@ func cmdNewGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbota
 			return err
 		}
 
+		packNames, err = userPackNames(ctx, userID, ActionAddGif)
+		if err != nil {
+			return err
+		}
+
 		text := "Which pack do you want to add a new gif to?"
 		reply = tgbotapi.NewMessage(chatID, text)
 
@@ -343,6 +398,10 @@ func cmdNewGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbota
 		}
 	}
 
+	if keyboard, ok := packNamesKeyboard(packNames); ok {
+		reply.ReplyMarkup = keyboard
+	}
+
 	_, err := bot.Send(reply)
 	if err != nil {
 		return err
@@ -351,42 +410,115 @@ func cmdNewGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbota
 	return nil
 }
 
-func cmdDeleteGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+func cmdSaveHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
 	userID := message.From.ID
 	chatID := message.Chat.ID
 
-	var state ConversationState
 	var text string
-	done := false
-	if packName := message.CommandArguments(); packName != "" {
-		_, err := GetPack(ctx, packName)
+	fileID, mediaType, ok := messageMedia(message.ReplyToMessage)
+	if args := strings.Fields(message.CommandArguments()); ok && len(args) >= 2 {
+		packName := args[0]
+		gif := Gif{
+			Pack:         search.Atom(packName),
+			FileID:       search.Atom(fileID),
+			FileUniqueID: search.Atom(fileUniqueID(ctx, fileID)),
+			Type:         search.Atom(mediaType),
+			Keywords:     strings.Join(args[1:], " "),
+		}
+
+		added, err := NewGif(ctx, packName, userID, gif)
 		if err != nil {
 			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
 			case ErrNotFound:
 				text = "Oops! There doesn't seem to be any gif pack with that name."
-				done = true
 			case ErrDeleted:
 				text = "Whoops, that gif pack has been deleted."
-				done = true
+			case ErrNotAllowed:
+				text = "Oops, it seems like you are not allowed to add gifs to this pack."
 			default:
 				return err
 			}
 		} else {
-			state = ConversationState{
-				State: stateDeleteGifWaitGif,
-				Data: map[string]string{
-					"packName": packName,
-				},
+			if added {
+				text = "Great! That gif has been saved to your gif pack."
+			} else {
+				text = "Don't worry, that gif is already part of this pack."
 			}
+		}
+	} else {
+		text = "Please reply to a gif with the gif pack and some keywords, like this: /save pack_name funny cat"
+	}
 
-			text = "Please send me the gif you want to delete from this pack."
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+	}
+
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdCopyGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	return startCopyGif(ctx, bot, message, false)
+}
+
+func cmdMoveGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	return startCopyGif(ctx, bot, message, true)
+}
+
+// startCopyGif starts copying or moving a gif from one pack to another. The source pack can be given as an argument.
+func startCopyGif(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, move bool) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	state := ConversationState{
+		State: stateCopyGifWaitSource,
+		Data:  make(map[string]string),
+	}
+	if move {
+		state.Data["move"] = "true"
+	}
+	verb := copyVerb(state)
+
+	var text string
+	var packNames []string
+	done := false
+	if packName := message.CommandArguments(); packName != "" {
+		pack, err := GetPack(ctx, packName)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			default:
+				return err
+			}
+			done = true
+		} else if !HasEditPermissions(pack, userID) {
+			text = fmt.Sprintf("Oops, it seems like you are not allowed to %s gifs out of this pack.", verb)
+			done = true
+		} else {
+			state.State = stateCopyGifWaitGif
+			state.Data["source"] = pack.Name
+			text = fmt.Sprintf("Please send me the gif you want to %s.", verb)
 		}
 	} else {
-		state = ConversationState{
-			State: stateDeleteGifWaitPackName,
+		var err error
+		packNames, err = userPackNames(ctx, userID, ActionEditGif)
+		if err != nil {
+			return err
 		}
 
-		text = "Which gif pack do you want to delete a gif from?"
+		text = fmt.Sprintf("Which gif pack do you want to %s a gif from?", verb)
 	}
 
 	if !done {
@@ -397,9 +529,9 @@ func cmdDeleteGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgb
 	}
 
 	reply := tgbotapi.NewMessage(chatID, text)
-
 	if !message.Chat.IsPrivate() {
 		reply.ReplyToMessageID = message.MessageID
+
 		if !done {
 			reply.ReplyMarkup = tgbotapi.ForceReply{
 				ForceReply: true,
@@ -408,6 +540,10 @@ func cmdDeleteGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgb
 		}
 	}
 
+	if keyboard, ok := packNamesKeyboard(packNames); ok {
+		reply.ReplyMarkup = keyboard
+	}
+
 	_, err := bot.Send(reply)
 	if err != nil {
 		return err
@@ -416,38 +552,138 @@ func cmdDeleteGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgb
 	return nil
 }
 
-func cmdDeletePackHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+func cmdMergePacksHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
 	userID := message.From.ID
 	chatID := message.Chat.ID
 
 	var text string
-	done := false
-	if name := message.CommandArguments(); name != "" {
-		err := SoftDeletePack(ctx, name, userID)
+	if args := strings.Fields(message.CommandArguments()); len(args) == 2 {
+		result, err := MergePacks(ctx, args[0], args[1], userID)
 		if err != nil {
 			switch err {
 			case ErrInvalidName:
 				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
-				done = true
 			case ErrNotFound:
-				text = "Oops, that gif pack doesn't exist. Did you type it in wrongly?"
-				done = true
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			case ErrSamePack:
+				text = "Oops, you can't merge a gif pack into itself."
+			case ErrNotAllowed:
+				text = "Oops, you can only merge gif packs which you own."
 			default:
 				return err
 			}
 		} else {
-			text = "Great! Your gif pack has been deleted."
-			done = true
+			text = fmt.Sprintf("Great! %s has been merged into %s and deleted. %s moved, %s had their keywords merged and %s moved over.",
+				strings.ToUpper(args[0]), strings.ToUpper(args[1]),
+				pluralise(result.Gifs, "gif was", "gifs were"),
+				pluralise(result.Merged, "gif", "gifs"),
+				pluralise(result.Subscribers, "subscriber was", "subscribers were"))
 		}
 	} else {
-		text = "What is the name of the gif pack you want to delete?"
+		text = "Please tell me the gif pack to merge and the gif pack to merge it into, like this: /mergepacks old_pack new_pack"
 	}
 
-	if !done {
-		state := ConversationState{
-			State: stateDeletePackWaitPackName,
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdForkPackHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var text string
+	if args := strings.Fields(message.CommandArguments()); len(args) == 2 {
+		created, err := ForkPack(ctx, args[0], args[1], userID)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			case ErrNotAllowed:
+				text = "Oops, only editors of a private gif pack can fork it."
+			default:
+				return err
+			}
+		} else {
+			if created {
+				text = fmt.Sprintf("Great! %s has been created with a copy of every gif in %s. It's all yours to customise.", args[1], strings.ToUpper(args[0]))
+			} else {
+				text = "Oops, a gif pack with that name already exists. Please choose another name for your fork."
+			}
+		}
+	} else {
+		text = "Please tell me the gif pack to fork and a name for your copy, like this: /forkpack their_pack my_pack"
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdEditGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var state ConversationState
+	var text string
+	var packNames []string
+	done := false
+	if packName := message.CommandArguments(); packName != "" {
+		pack, err := GetPack(ctx, packName)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			default:
+				return err
+			}
+			done = true
+		} else if !HasEditPermissions(pack, userID) {
+			text = "Oops, it seems like you are not allowed to edit gifs in this pack."
+			done = true
+		} else {
+			state = ConversationState{
+				State: stateEditGifWaitGif,
+				Data: map[string]string{
+					"packName": pack.Name,
+				},
+			}
+
+			text = "Please send me the gif you want to edit."
+		}
+	} else {
+		state = ConversationState{
+			State: stateEditGifWaitPackName,
+		}
+
+		var err error
+		packNames, err = userPackNames(ctx, userID, ActionEditGif)
+		if err != nil {
+			return err
 		}
 
+		text = "Which gif pack do you want to edit a gif in?"
+	}
+
+	if !done {
 		err := SetConversationState(ctx, chatID, userID, state)
 		if err != nil {
 			return err
@@ -466,17 +702,10 @@ func cmdDeletePackHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tg
 		}
 	}
 
-	_, err := bot.Send(reply)
-	if err != nil {
-		return err
+	if keyboard, ok := packNamesKeyboard(packNames); ok {
+		reply.ReplyMarkup = keyboard
 	}
 
-	return nil
-}
-
-func cmdVersionHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
-	chatID := message.Chat.ID
-	reply := tgbotapi.NewMessage(chatID, fmt.Sprintf("Saved GIFs Bot version %s", Version))
 	_, err := bot.Send(reply)
 	if err != nil {
 		return err
@@ -485,11 +714,1066 @@ func cmdVersionHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbot
 	return nil
 }
 
-func cmdCancelHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+func cmdDeleteGifHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
 	chatID := message.Chat.ID
 
-	reply := tgbotapi.NewMessage(chatID, "Command cancelled!")
-	_, err := bot.Send(reply)
+	var state ConversationState
+	var text string
+	var packNames []string
+	done := false
+	if packName := message.CommandArguments(); packName != "" {
+		_, err := GetPack(ctx, packName)
+		if err != nil {
+			switch err {
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+				done = true
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+				done = true
+			default:
+				return err
+			}
+		} else {
+			state = ConversationState{
+				State: stateDeleteGifWaitGif,
+				Data: map[string]string{
+					"packName": packName,
+				},
+			}
+
+			text = "Please send me the gif you want to delete from this pack."
+		}
+	} else {
+		state = ConversationState{
+			State: stateDeleteGifWaitPackName,
+		}
+
+		var err error
+		packNames, err = userPackNames(ctx, userID, ActionDeleteGif)
+		if err != nil {
+			return err
+		}
+
+		text = "Which gif pack do you want to delete a gif from?"
+	}
+
+	if !done {
+		err := SetConversationState(ctx, chatID, userID, state)
+		if err != nil {
+			return err
+		}
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+		if !done {
+			reply.ReplyMarkup = tgbotapi.ForceReply{
+				ForceReply: true,
+				Selective:  true,
+			}
+		}
+	}
+
+	if keyboard, ok := packNamesKeyboard(packNames); ok {
+		reply.ReplyMarkup = keyboard
+	}
+
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdDeletePackHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var text string
+	done := false
+	if name := message.CommandArguments(); name != "" {
+		err := SoftDeletePack(ctx, name, userID)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+				done = true
+			case ErrNotFound:
+				text = "Oops, that gif pack doesn't exist. Did you type it in wrongly?"
+				done = true
+			default:
+				return err
+			}
+		} else {
+			text = "Great! Your gif pack has been deleted."
+			done = true
+		}
+	} else {
+		text = "What is the name of the gif pack you want to delete?"
+	}
+
+	if !done {
+		state := ConversationState{
+			State: stateDeletePackWaitPackName,
+		}
+
+		err := SetConversationState(ctx, chatID, userID, state)
+		if err != nil {
+			return err
+		}
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+
+		if !done {
+			reply.ReplyMarkup = tgbotapi.ForceReply{
+				ForceReply: true,
+				Selective:  true,
+			}
+		}
+	}
+
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdVersionHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	reply := tgbotapi.NewMessage(chatID, fmt.Sprintf("Saved GIFs Bot version %s", Version))
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdDoneHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	state, err := GetConversationState(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	if state.Data["batch"] == "true" {
+		err := ClearConversationState(ctx, chatID, userID)
+		if err != nil {
+			return err
+		}
+
+		text = batchSummary(state)
+	} else {
+		text = "Oops, there is nothing to finish right now. You can use /newgifs to add several gifs to a pack at once."
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	reply.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+	}
+
+	_, err = bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdNoneHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	state, err := GetConversationState(ctx, message.Chat.ID, message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	if clearableStates[state.State] {
+		return Transduce(ctx, bot, message)
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, "Oops, there is nothing to clear right now.")
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+	}
+
+	_, err = bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdSkipHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	state, err := GetConversationState(ctx, message.Chat.ID, message.From.ID)
+	if err != nil {
+		return err
+	}
+
+	// let the current step decide what skipping means
+	if skippableStates[state.State] {
+		return Transduce(ctx, bot, message)
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, "Oops, there is nothing to skip right now.")
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+	}
+
+	_, err = bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdCancelHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+
+	reply := tgbotapi.NewMessage(chatID, "Command cancelled!")
+	reply.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdStartHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var text string
+	payload := message.CommandArguments()
+	if strings.HasPrefix(payload, invitePayloadPrefix) {
+		token := strings.TrimPrefix(payload, invitePayloadPrefix)
+		packName, added, err := RedeemInvite(ctx, token, userID)
+		if err != nil {
+			switch err {
+			case ErrInviteInvalid:
+				text = "Oh no! That invite link is invalid or has expired. Please ask for a new one."
+			case ErrNotFound, ErrDeleted:
+				text = "Whoops, the gif pack that invite was for no longer exists."
+			default:
+				return err
+			}
+		} else {
+			// subscribe the new member straight away, so that private packs can be used without asking for another link
+			_, err := Subscribe(ctx, packName, userID)
+			if err != nil {
+				return err
+			}
+
+			if added {
+				text = fmt.Sprintf("Great! You are now a member of %s and have been subscribed to it. Use /mypacks to see all the gif packs you are a member of.", packName)
+			} else {
+				text = fmt.Sprintf("Don't worry, you are already a member of %s.", packName)
+			}
+		}
+	} else if strings.HasPrefix(payload, subscribePayloadPrefix) {
+		packName := strings.TrimPrefix(payload, subscribePayloadPrefix)
+		subscribed, err := Subscribe(ctx, packName, userID)
+		if err != nil {
+			switch err {
+			case ErrInvalidName, ErrNotFound, ErrDeleted:
+				text = "Oops! The gif pack that link was for doesn't seem to exist anymore."
+			default:
+				return err
+			}
+		} else {
+			if subscribed {
+				text = fmt.Sprintf("Great! You have been subscribed to %s! Try it out by typing @%s %s in any chat.", packName, botUsername(), packName)
+			} else {
+				text = fmt.Sprintf("Don't worry, you are already subscribed to %s!", packName)
+			}
+		}
+	} else {
+		text = "Hi! I can help you organise your gifs into gif packs. Use /newpack to create your first gif pack, or /sub to subscribe to an existing one."
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// subscribeLink returns a deep link which will subscribe whoever opens it to pack.
+func subscribeLink(pack Pack) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%s", botUsername(), subscribePayloadPrefix, pack.Name)
+}
+
+// inviteLink returns a deep link which will redeem invite when opened.
+func inviteLink(invite Invite) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%s", botUsername(), invitePayloadPrefix, invite.Token)
+}
+
+func cmdInviteHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var text string
+	done := false
+	if args := strings.Fields(message.CommandArguments()); len(args) > 0 {
+		packName := args[0]
+		role := RoleEditor
+		if len(args) > 1 {
+			var ok bool
+			role, ok = ParseRole(args[1])
+			if !ok {
+				role = RoleNone
+			}
+		}
+
+		invite, err := NewInvite(ctx, packName, userID, role)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+				done = true
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+				done = true
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+				done = true
+			case ErrInvalidRole:
+				text = "Oh no! That was not a valid role. You can invite people as an editor, adder or viewer."
+				done = true
+			case ErrNotAllowed:
+				text = "Oops, it seems like you are not the owner of this pack. Only the pack owner can invite people to it."
+				done = true
+			default:
+				return err
+			}
+		} else {
+			text = fmt.Sprintf("Great! Send this link to the person you want to invite as %s. It can only be used once and expires on %s.\n%s",
+				invite.Role, invite.Expires.Format("2 Jan 2006"), inviteLink(invite))
+			done = true
+		}
+	} else {
+		text = "Which gif pack do you want to invite someone to?"
+	}
+
+	if !done {
+		state := ConversationState{
+			State: stateInviteWaitPackName,
+		}
+
+		err := SetConversationState(ctx, chatID, userID, state)
+		if err != nil {
+			return err
+		}
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+
+		if !done {
+			reply.ReplyMarkup = tgbotapi.ForceReply{
+				ForceReply: true,
+				Selective:  true,
+			}
+		}
+	}
+
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdInvitesHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var text string
+	if packName := message.CommandArguments(); packName != "" {
+		invites, err := GetInvites(ctx, packName, userID)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			case ErrNotAllowed:
+				text = "Oops, it seems like you are not the owner of this pack. Only the pack owner can see its invites."
+			default:
+				return err
+			}
+		} else {
+			if len(invites) > 0 {
+				text = "Here are the outstanding invites for this gif pack:\n"
+
+				for i, invite := range invites {
+					text += fmt.Sprintf("%d. %s as %s (expires %s)\n", i+1, invite.Token, invite.Role, invite.Expires.Format("2 Jan 2006"))
+				}
+
+				text += "Use /revokeinvite followed by an invite to revoke it."
+			} else {
+				text = "There are no outstanding invites for this gif pack. Use /invite to create one."
+			}
+		}
+	} else {
+		text = "Please tell me which gif pack you want to see the invites for, like this: /invites pack_name"
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdRevokeInviteHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var text string
+	if token := message.CommandArguments(); token != "" {
+		revoked, err := RevokeInvite(ctx, token, userID)
+		if err != nil {
+			if err == ErrNotAllowed {
+				text = "Oops, it seems like you are not the owner of the pack this invite is for. Only the pack owner can revoke invites."
+			} else {
+				return err
+			}
+		} else {
+			if revoked {
+				text = "Great! That invite has been revoked."
+			} else {
+				text = "Oops, I couldn't find that invite. Perhaps it has already been used or revoked?"
+			}
+		}
+	} else {
+		text = "Please tell me which invite you want to revoke, like this: /revokeinvite invite. Use /invites to see your outstanding invites."
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdMembersHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var text string
+	if packName := message.CommandArguments(); packName != "" {
+		pack, err := GetPack(ctx, packName)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			default:
+				return err
+			}
+		} else {
+			if PackRole(pack, userID) != RoleNone {
+				text = membersText(pack)
+			} else {
+				text = "Oops, it seems like you are not a member of this pack. Only pack members can see who else is in it."
+			}
+		}
+	} else {
+		text = "Please tell me which gif pack you want to see the members of, like this: /members pack_name"
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdSetRoleHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var text string
+	if args := strings.Fields(message.CommandArguments()); len(args) == 3 {
+		member, err := strconv.Atoi(args[1])
+		if err != nil {
+			text = "Oh no! That was not a valid user id. Use /members to see the user ids of a pack's members."
+		} else {
+			role, ok := ParseRole(args[2])
+			if !ok {
+				role = RoleOwner
+			}
+
+			changed, err := SetMemberRole(ctx, args[0], userID, member, role)
+			if err != nil {
+				switch err {
+				case ErrInvalidName:
+					text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+				case ErrNotFound:
+					text = "Oops! There doesn't seem to be any gif pack with that name."
+				case ErrDeleted:
+					text = "Whoops, that gif pack has been deleted."
+				case ErrInvalidRole:
+					text = "Oh no! That was not a valid role. A member can be an editor, adder or viewer, or none to remove them from the pack."
+				case ErrNotAllowed:
+					text = "Oops, only the pack owner can change the roles of its members, and the owner's own role cannot be changed."
+				default:
+					return err
+				}
+			} else {
+				if changed {
+					text = "Great! That member's role has been updated."
+				} else {
+					text = "Don't worry, that member already has that role."
+				}
+			}
+		}
+	} else {
+		text = "Please tell me the gif pack, member and role, like this: /setrole pack_name user_id editor. Use /members to see the user ids of a pack's members."
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// transferPrompt returns the text asking the owner of pack which contributor to transfer it to.
+func transferPrompt(pack Pack) string {
+	if len(pack.Contributors) == 0 {
+		return "Oops, this gif pack has no contributors to transfer it to. Use /invite to invite someone as an editor first."
+	}
+
+	text := "Please send me the user id of the contributor you want to transfer this gif pack to:\n"
+	for _, c := range pack.Contributors {
+		text += fmt.Sprintf("%d\n", c)
+	}
+
+	return text
+}
+
+// notifyTransfer lets the new owner of a pack know that a transfer is waiting for them. It is not an error if the new
+// owner has never started a conversation with the bot, so failures are only logged.
+func notifyTransfer(ctx context.Context, bot *tgbotapi.BotAPI, packName string, newOwner int) {
+	text := fmt.Sprintf("You have been asked to become the owner of the gif pack %s. Use /accepttransfer %s to accept or /declinetransfer %s to decline.", packName, packName, packName)
+	_, err := bot.Send(tgbotapi.NewMessage(int64(newOwner), text))
+	if err != nil {
+		log.Warningf(ctx, "%v", err)
+	}
+}
+
+func cmdTransferPackHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var state ConversationState
+	var text string
+	done := false
+	if packName := message.CommandArguments(); packName != "" {
+		pack, err := GetPack(ctx, packName)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+				done = true
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+				done = true
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+				done = true
+			default:
+				return err
+			}
+		} else {
+			if !Can(pack, userID, ActionManageMembers) {
+				text = "Oops, it seems like you are not the owner of this pack. Only the pack owner can transfer it."
+				done = true
+			} else if len(pack.Contributors) == 0 {
+				text = transferPrompt(pack)
+				done = true
+			} else {
+				state = ConversationState{
+					State: stateTransferPackWaitNewOwner,
+					Data: map[string]string{
+						"packName": packName,
+					},
+				}
+
+				text = transferPrompt(pack)
+			}
+		}
+	} else {
+		state = ConversationState{
+			State: stateTransferPackWaitPackName,
+		}
+
+		text = "Which gif pack do you want to transfer?"
+	}
+
+	if !done {
+		err := SetConversationState(ctx, chatID, userID, state)
+		if err != nil {
+			return err
+		}
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+
+		if !done {
+			reply.ReplyMarkup = tgbotapi.ForceReply{
+				ForceReply: true,
+				Selective:  true,
+			}
+		}
+	}
+
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdAcceptTransferHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	packName := message.CommandArguments()
+	if packName == "" {
+		// accept the only pending transfer if there is exactly one
+		transfers, err := GetPendingTransfers(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if len(transfers) == 1 {
+			packName = transfers[0].Pack
+		}
+	}
+
+	var text string
+	if packName != "" {
+		err := AcceptTransfer(ctx, packName, userID)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNoTransfer, ErrNotFound:
+				text = "Oops, there is no pending transfer of that gif pack to you."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			default:
+				return err
+			}
+		} else {
+			text = "Great! You are now the owner of this gif pack."
+		}
+	} else {
+		text = "Please tell me which gif pack you want to become the owner of, like this: /accepttransfer pack_name"
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdDeclineTransferHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var text string
+	if packName := message.CommandArguments(); packName != "" {
+		err := CancelTransfer(ctx, packName, userID)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNoTransfer:
+				text = "Oops, there is no pending transfer of that gif pack involving you."
+			default:
+				return err
+			}
+		} else {
+			text = "Alright, that transfer has been cancelled."
+		}
+	} else {
+		text = "Please tell me which gif pack's transfer you want to cancel, like this: /declinetransfer pack_name"
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdAuditHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var text string
+	if packName := message.CommandArguments(); packName != "" {
+		entries, err := GetAuditTrail(ctx, packName, userID)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			case ErrNotAllowed:
+				text = "Oops, it seems like you are not the owner of this pack. Only the pack owner can see its history."
+			default:
+				return err
+			}
+		} else {
+			if len(entries) > 0 {
+				text = "Here is the history of this gif pack:\n"
+
+				for _, entry := range entries {
+					text += fmt.Sprintf("%s: %s by %d", entry.Time.Format("2 Jan 2006 15:04"), entry.Action, entry.Actor)
+					if entry.Subject != 0 {
+						text += fmt.Sprintf(" (%d)", entry.Subject)
+					}
+					text += "\n"
+				}
+			} else {
+				text = "Nothing has been recorded for this gif pack yet."
+			}
+		}
+	} else {
+		text = "Please tell me which gif pack you want to see the history of, like this: /audit pack_name"
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdSetVisibilityHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var text string
+	if args := strings.Fields(message.CommandArguments()); len(args) == 2 {
+		visibility := strings.ToLower(args[1])
+		err := SetPackVisibility(ctx, args[0], userID, visibility)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			case ErrInvalidVisibility:
+				text = "Oh no! That was not a valid visibility. A gif pack can be public, unlisted or private."
+			case ErrNotAllowed:
+				text = "Oops, it seems like you are not the owner of this pack. Only the pack owner can change its visibility."
+			default:
+				return err
+			}
+		} else {
+			text = fmt.Sprintf("Great! This gif pack is now %s.", visibility)
+		}
+	} else {
+		text = "Please tell me the gif pack and its new visibility, like this: /setvisibility pack_name private. A gif pack can be public, unlisted or private."
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdBrowseHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+
+	sortBy := SortPopular
+	if message.CommandArguments() == SortRecent {
+		sortBy = SortRecent
+	}
+
+	packs, more, err := BrowsePacks(ctx, sortBy, 0)
+	if err != nil {
+		return err
+	}
+
+	var reply tgbotapi.MessageConfig
+	if len(packs) > 0 {
+		text, markup := browseMessage(packs, sortBy, 0, more)
+		reply = tgbotapi.NewMessage(chatID, text)
+		reply.ReplyMarkup = markup
+	} else {
+		reply = tgbotapi.NewMessage(chatID, "Oops, there aren't any public gif packs yet. Why not create one with /newpack?")
+	}
+
+	_, err = bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdFindPackHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+
+	var reply tgbotapi.MessageConfig
+	if text := message.CommandArguments(); text != "" {
+		packs, err := FindPacks(ctx, text)
+		if err != nil {
+			return err
+		}
+
+		if len(packs) > 0 {
+			text := "Here are the public gif packs I found:\n"
+			for i, pack := range packs {
+				text += fmt.Sprintf("%d. %s\n", i+1, packSummary(pack))
+			}
+
+			reply = tgbotapi.NewMessage(chatID, text)
+			reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(subscribeButtons(packs)...)
+		} else {
+			reply = tgbotapi.NewMessage(chatID, "Oops, I couldn't find any public gif packs with that name.")
+		}
+	} else {
+		reply = tgbotapi.NewMessage(chatID, "Please tell me what to look for, like this: /findpack cats")
+	}
+
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cmdListGifsHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+
+	var text string
+	if packName := message.CommandArguments(); packName != "" {
+		ok, err := sendGifsPage(ctx, bot, chatID, message.From.ID, packName, 0)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			default:
+				return err
+			}
+		} else if ok {
+			return nil
+		} else {
+			text = "Oops, there aren't any gifs in that pack yet."
+		}
+	} else {
+		text = "Please tell me which gif pack to list, like this: /listgifs my_pack"
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// sendGifsPage sends page of the gifs in packName to chatID one by one with their keywords, followed by a message with
+// buttons to go to the other pages. Gifs get Edit and Delete buttons if userID is allowed to use them. ok will be false
+// if there were no gifs on page.
+func sendGifsPage(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64, userID int, packName string, page int) (ok bool, err error) {
+	gifs, more, err := ListGifs(ctx, packName, userID, page)
+	if err != nil {
+		return false, err
+	}
+
+	if len(gifs) == 0 {
+		return false, nil
+	}
+
+	pack, err := GetPack(ctx, packName)
+	if err != nil {
+		return false, err
+	}
+
+	for _, gif := range gifs {
+		var markup interface{}
+		if buttons, ok := gifButtons(pack, gif, userID); ok {
+			markup = buttons
+		}
+
+		_, err := bot.Send(mediaShare(chatID, gif, gif.Keywords, markup))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	text, markup := listGifsMessage(pack, gifs, page, more)
+	reply := tgbotapi.NewMessage(chatID, text)
+	if len(markup.InlineKeyboard) > 0 {
+		reply.ReplyMarkup = markup
+	}
+
+	_, err = bot.Send(reply)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func cmdKeywordsHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+
+	var text string
+	if packName := message.CommandArguments(); packName != "" {
+		keywords, err := PackKeywords(ctx, packName, message.From.ID)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			default:
+				return err
+			}
+		} else {
+			text = keywordsMessage(strings.ToUpper(packName), keywords)
+		}
+	} else {
+		text = "Please tell me which gif pack's keywords you want to see, like this: /keywords my_pack"
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.Send(reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// synonymsUsage explains the forms of /synonyms.
+const synonymsUsage = "Use /synonyms pack_name to see a gif pack's synonyms, /synonyms pack_name add lol haha laugh to make some words find the same gifs, and /synonyms pack_name remove 1 to remove a group of synonyms."
+
+func cmdSynonymsHandler(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	var err error
+	var text string
+	args := strings.Fields(message.CommandArguments())
+	switch {
+	case len(args) == 1:
+		var pack Pack
+		pack, err = GetPack(ctx, args[0])
+		if err == nil && !Can(pack, userID, ActionView) {
+			err = ErrNotFound
+		}
+		if err == nil {
+			text = synonymsMessage(pack)
+		}
+	case len(args) > 2 && args[1] == "add":
+		err = AddSynonyms(ctx, args[0], userID, strings.Join(args[2:], " "))
+		if err == nil {
+			text = fmt.Sprintf("Great! Searching %s for any of %s will now find the same gifs.", strings.ToUpper(args[0]), synonymGroup(strings.Join(args[2:], " ")))
+		}
+	case len(args) == 3 && args[1] == "remove":
+		// anything which is not a number is reported as a missing group
+		n, _ := strconv.Atoi(args[2])
+		err = RemoveSynonyms(ctx, args[0], userID, n)
+		if err == nil {
+			text = "Great! That group of synonyms has been removed."
+		}
+	default:
+		text = synonymsUsage
+	}
+
+	if err != nil {
+		switch err {
+		case ErrInvalidName:
+			text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+		case ErrNotFound:
+			text = "Oops! There doesn't seem to be any gif pack with that name."
+		case ErrDeleted:
+			text = "Whoops, that gif pack has been deleted."
+		case ErrNotAllowed:
+			text = "Oops, it seems like you are not allowed to change the synonyms of this pack."
+		case ErrTooFewSynonyms:
+			text = "Oh no! A group of synonyms needs at least two different words."
+		case ErrTooManySynonymGroups:
+			text = fmt.Sprintf("Oops, a gif pack can only have %d groups of synonyms. Please remove one first.", maxSynonymGroups)
+		case ErrSynonymGroupDuplicate:
+			text = "Don't worry, that group of synonyms already exists!"
+		case ErrSynonymGroupNotFound:
+			text = "Oops, there is no group of synonyms with that number. Use /synonyms pack_name to see them."
+		default:
+			return err
+		}
+	}
+
+	reply := tgbotapi.NewMessage(chatID, text)
+	_, err = bot.Send(reply)
 	if err != nil {
 		return err
 	}
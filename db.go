@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
+	"google.golang.org/appengine"
 	"google.golang.org/appengine/datastore"
 	"google.golang.org/appengine/search"
 )
@@ -16,6 +18,9 @@ const (
 	gifsIndex = "Gifs"
 )
 
+// listGifsPageSize is the number of gifs shown on each page of /listgifs
+const listGifsPageSize = 5
+
 // app engine datastore kinds
 const (
 	packKind         = "Pack"
@@ -33,21 +38,58 @@ var (
 	ErrNotAllowed  = errors.New("not allowed")
 	ErrNotFound    = errors.New("pack not found")
 	ErrDeleted     = errors.New("pack deleted")
+	ErrInvalidRole = errors.New("invalid role")
+
+	ErrInvalidVisibility = errors.New("invalid visibility")
+)
+
+// pack visibilities
+const (
+	// VisibilityPublic packs can be found and subscribed to by anyone
+	VisibilityPublic = "public"
+	// VisibilityUnlisted packs can be subscribed to by anyone who knows their name, but are not listed anywhere
+	VisibilityUnlisted = "unlisted"
+	// VisibilityPrivate packs can only be subscribed to and searched by their members
+	VisibilityPrivate = "private"
 )
 
-// Gif represents a gif in our search index
+// Gif represents a gif in our search index. Despite its name, it can also be a sticker, photo, video or voice note,
+// depending on its Type. FileID is used to send the gif, while FileUniqueID identifies it however it was sent. Title,
+// Caption and AltText are optional. Keywords are kept as they were typed for showing to users, while Terms holds their
+// normalised form, which is what gets searched. TitleTerms is the same for Title. Added is when the gif was added to its
+// pack, and is zero for gifs added before it was recorded.
 type Gif struct {
-	Pack     search.Atom
-	FileID   search.Atom
-	Keywords string
+	Pack         search.Atom
+	FileID       search.Atom
+	FileUniqueID search.Atom
+	Type         search.Atom
+	Keywords     string
+	Terms        string
+	Title        string
+	TitleTerms   string
+	Caption      string
+	AltText      string
+	Added        time.Time
 }
 
-// Pack represents a gif pack in datastore
+// Pack represents a gif pack in datastore. The creator of a pack is its owner, and Contributors, Adders and Viewers
+// hold the users with the editor, adder and viewer roles respectively. Gifs and Subscribers are running counts kept so
+// that packs can be listed by popularity without counting them on every request. ForkedFrom is the name of the pack
+// this pack was forked from, if any. Synonyms holds groups of space separated words which find the same gifs when
+// searching the pack.
 type Pack struct {
 	Name         string
 	Creator      int
 	Contributors []int
+	Adders       []int
+	Viewers      []int
+	Visibility   string
+	Gifs         int
+	Subscribers  int
+	Created      time.Time
 	Deleted      bool
+	ForkedFrom   string
+	Synonyms     []string
 }
 
 // Subscription represents a subscription to a gif pack in datastore
@@ -56,10 +98,12 @@ type Subscription struct {
 	Pack   string
 }
 
-// UserPacks represents the packs a user has created and is a contributor to
+// UserPacks represents the packs a user has created and is a member of
 type UserPacks struct {
 	IsCreator     []Pack
 	IsContributor []Pack
+	IsAdder       []Pack
+	IsViewer      []Pack
 }
 
 // NewPack returns true if pack was created, false if a pack with the same name already exists.
@@ -80,8 +124,10 @@ func NewPack(ctx context.Context, packName string, creator int) (bool, error) {
 	}
 
 	pack = Pack{
-		Name:    packName,
-		Creator: creator,
+		Name:       packName,
+		Creator:    creator,
+		Visibility: VisibilityPublic,
+		Created:    time.Now(),
 	}
 
 	key := datastore.NewKey(ctx, packKind, strings.ToUpper(packName), 0, nil)
@@ -93,7 +139,7 @@ func NewPack(ctx context.Context, packName string, creator int) (bool, error) {
 	return true, nil
 }
 
-// GetUserPacks returns a UserPacks struct representing the packs a user has created and is a contributor to
+// GetUserPacks returns a UserPacks struct representing the packs a user has created and is a member of
 func GetUserPacks(ctx context.Context, userID int) (UserPacks, error) {
 	var isCreator []Pack
 	q1 := datastore.
@@ -115,9 +161,31 @@ func GetUserPacks(ctx context.Context, userID int) (UserPacks, error) {
 		return UserPacks{}, err
 	}
 
+	var isAdder []Pack
+	q3 := datastore.
+		NewQuery(packKind).
+		Filter("Adders =", userID).
+		Filter("Deleted = ", false)
+	_, err = q3.GetAll(ctx, &isAdder)
+	if err != nil {
+		return UserPacks{}, err
+	}
+
+	var isViewer []Pack
+	q4 := datastore.
+		NewQuery(packKind).
+		Filter("Viewers =", userID).
+		Filter("Deleted = ", false)
+	_, err = q4.GetAll(ctx, &isViewer)
+	if err != nil {
+		return UserPacks{}, err
+	}
+
 	userPacks := UserPacks{
 		IsCreator:     isCreator,
 		IsContributor: isContributor,
+		IsAdder:       isAdder,
+		IsViewer:      isViewer,
 	}
 
 	return userPacks, nil
@@ -164,78 +232,132 @@ func SetPack(ctx context.Context, pack *Pack) error {
 	return nil
 }
 
-// NewContributor adds a contributor to a gif pack
-func NewContributor(ctx context.Context, packName string, creator, contributor int) (bool, error) {
+// updatePackCounts adjusts the gif and subscriber counts of pack by gifs and subscribers. It must not be called inside
+// a transaction.
+func updatePackCounts(ctx context.Context, packName string, gifs, subscribers int) error {
+	key := datastore.NewKey(ctx, packKind, strings.ToUpper(packName), 0, nil)
+	return datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		var pack Pack
+		err := datastore.Get(tc, key, &pack)
+		if err != nil {
+			return err
+		}
+
+		pack.Gifs += gifs
+		if pack.Gifs < 0 {
+			pack.Gifs = 0
+		}
+
+		pack.Subscribers += subscribers
+		if pack.Subscribers < 0 {
+			pack.Subscribers = 0
+		}
+
+		_, err = datastore.Put(tc, key, &pack)
+		return err
+	}, nil)
+}
+
+// packVisibility returns the visibility of pack. Packs created before visibilities were introduced are public.
+func packVisibility(pack Pack) string {
+	if pack.Visibility == "" {
+		return VisibilityPublic
+	}
+
+	return pack.Visibility
+}
+
+// SetPackVisibility changes the visibility of a gif pack. Only users who can manage the settings of pack can change its
+// visibility.
+func SetPackVisibility(ctx context.Context, packName string, userID int, visibility string) error {
 	// validate pack name
 	if !packNameRegex.MatchString(packName) {
-		return false, ErrInvalidName
+		return ErrInvalidName
+	}
+
+	switch visibility {
+	case VisibilityPublic, VisibilityUnlisted, VisibilityPrivate:
+	default:
+		return ErrInvalidVisibility
 	}
 
-	// check that pack exists and creator is the creator of pack
 	pack, err := GetPack(ctx, packName)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	if creator != pack.Creator {
-		return false, ErrNotAllowed
+	if !Can(pack, userID, ActionManageSettings) {
+		return ErrNotAllowed
 	}
 
-	// check that contributor is not already in pack
-	for _, c := range pack.Contributors {
-		if contributor == c {
-			return false, nil
-		}
-	}
+	pack.Visibility = visibility
+	return SetPack(ctx, &pack)
+}
 
-	// update pack
-	pack.Contributors = append(pack.Contributors, contributor)
-	err = SetPack(ctx, &pack)
-	if err != nil {
-		return false, err
+// NewContributor adds a contributor to a gif pack
+func NewContributor(ctx context.Context, packName string, creator, contributor int) (bool, error) {
+	return SetMemberRole(ctx, packName, creator, contributor, RoleEditor)
+}
+
+// removeUser returns users without userID, and whether userID was found in users.
+func removeUser(users []int, userID int) ([]int, bool) {
+	for i, u := range users {
+		if userID == u {
+			users[i] = users[len(users)-1]
+			return users[:len(users)-1], true
+		}
 	}
 
-	return true, nil
+	return users, false
 }
 
-// DeleteContributor removes a contributor from a gif pack
-func DeleteContributor(ctx context.Context, packName string, creator, contributor int) (bool, error) {
+// SetMemberRole gives member role in a gif pack, replacing any role member had before. RoleNone removes member from
+// the pack. Returns true if member's role was changed, false if member already had role. The owner of a pack cannot be
+// changed with SetMemberRole.
+func SetMemberRole(ctx context.Context, packName string, owner, member int, role Role) (bool, error) {
 	// validate pack name
 	if !packNameRegex.MatchString(packName) {
 		return false, ErrInvalidName
 	}
 
-	// check that creator is the creator of pack
+	if role == RoleOwner {
+		return false, ErrInvalidRole
+	}
+
+	// check that pack exists and owner can manage its members
 	pack, err := GetPack(ctx, packName)
 	if err != nil {
 		return false, err
 	}
 
-	if creator != pack.Creator {
+	if !Can(pack, owner, ActionManageMembers) {
 		return false, ErrNotAllowed
 	}
 
-	// check that contributor is in pack
-	var index int
-	found := false
-	for i, c := range pack.Contributors {
-		if contributor == c {
-			index = i
-			found = true
-			break
-		}
+	current := PackRole(pack, member)
+	if current == RoleOwner {
+		return false, ErrNotAllowed
 	}
 
-	if !found {
+	if current == role {
 		return false, nil
 	}
 
-	// remove contributor from pack.Contributors
-	a := pack.Contributors
-	a[index] = a[len(a)-1]
+	// remove member from their current role
+	pack.Contributors, _ = removeUser(pack.Contributors, member)
+	pack.Adders, _ = removeUser(pack.Adders, member)
+	pack.Viewers, _ = removeUser(pack.Viewers, member)
+
+	switch role {
+	case RoleEditor:
+		pack.Contributors = append(pack.Contributors, member)
+	case RoleAdder:
+		pack.Adders = append(pack.Adders, member)
+	case RoleViewer:
+		pack.Viewers = append(pack.Viewers, member)
+	}
 
 	// update pack
-	pack.Contributors = a[:len(a)-1]
 	err = SetPack(ctx, &pack)
 	if err != nil {
 		return false, err
@@ -244,8 +366,13 @@ func DeleteContributor(ctx context.Context, packName string, creator, contributo
 	return true, nil
 }
 
+// DeleteContributor removes a member from a gif pack
+func DeleteContributor(ctx context.Context, packName string, creator, contributor int) (bool, error) {
+	return SetMemberRole(ctx, packName, creator, contributor, RoleNone)
+}
+
 // Subscribe returns true if user was successfully subscribed to pack, false if user was already subscribed to pack.
-// err will be ErrNotFound if pack does not exist.
+// err will be ErrNotFound if pack does not exist, or if pack is private and user is not a member of it.
 func Subscribe(ctx context.Context, packName string, userID int) (bool, error) {
 	// validate pack name
 	if !packNameRegex.MatchString(packName) {
@@ -255,12 +382,16 @@ func Subscribe(ctx context.Context, packName string, userID int) (bool, error) {
 	// normalise pack name
 	packName = strings.ToUpper(packName)
 
-	// check if pack exists
-	_, err := GetPack(ctx, packName)
+	// check if pack exists and user can see it
+	pack, err := GetPack(ctx, packName)
 	if err != nil {
 		return false, err
 	}
 
+	if !Can(pack, userID, ActionView) {
+		return false, ErrNotFound
+	}
+
 	// check if userID is already subscribed
 	var s Subscription
 	key := datastore.NewKey(ctx, subscriptionKind, fmt.Sprintf("%d:%s", userID, packName), 0, nil)
@@ -283,6 +414,11 @@ func Subscribe(ctx context.Context, packName string, userID int) (bool, error) {
 		return false, err
 	}
 
+	err = updatePackCounts(ctx, packName, 0, 1)
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
@@ -320,6 +456,11 @@ func Unsubscribe(ctx context.Context, packName string, userID int) (bool, error)
 		return false, err
 	}
 
+	err = updatePackCounts(ctx, packName, 0, -1)
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
@@ -346,15 +487,16 @@ func MySubscriptions(ctx context.Context, user int) ([]Subscription, error) {
 	return mysubs, nil
 }
 
-// GetGif is a convenience wrapper to get a gif by packName and fileID from the search index
-func GetGif(ctx context.Context, packName, fileID string) (Gif, error) {
+// GetGif is a convenience wrapper to get a gif by packName and id from the search index. id is the file unique id of
+// the gif, or its file id for gifs saved before file unique ids were recorded.
+func GetGif(ctx context.Context, packName, id string) (Gif, error) {
 	index, err := search.Open(gifsIndex)
 	if err != nil {
 		return Gif{}, err
 	}
 
 	var gif Gif
-	key := fmt.Sprintf("%s:%s", packName, fileID)
+	key := gifKey(packName, id)
 	err = index.Get(ctx, key, &gif)
 	if err != nil {
 		if err == search.ErrNoSuchDocument {
@@ -367,19 +509,51 @@ func GetGif(ctx context.Context, packName, fileID string) (Gif, error) {
 	return gif, nil
 }
 
-// HasEditPermissions returns true if userID is the creator of pack or a contributor to pack.
-func HasEditPermissions(pack Pack, userID int) bool {
-	if userID == pack.Creator {
-		return true
+// Load implements search.FieldLoadSaver.
+func (gif *Gif) Load(fields []search.Field, meta *search.DocumentMetadata) error {
+	return search.LoadStruct(gif, fields)
+}
+
+// Save implements search.FieldLoadSaver. Added is left out for gifs added before it was recorded, since the search
+// index can't store the zero time and would turn it into a date in 1754.
+func (gif *Gif) Save() ([]search.Field, *search.DocumentMetadata, error) {
+	fields, err := search.SaveStruct(gif)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	for _, c := range pack.Contributors {
-		if userID == c {
-			return true
+	if !gif.Added.IsZero() {
+		return fields, nil, nil
+	}
+
+	saved := fields[:0]
+	for _, field := range fields {
+		if field.Name != "Added" {
+			saved = append(saved, field)
 		}
 	}
 
-	return false
+	return saved, nil, nil
+}
+
+// gifID returns the id gif is stored under, which is its file unique id, or its file id for gifs saved before file
+// unique ids were recorded.
+func gifID(gif Gif) string {
+	if gif.FileUniqueID != "" {
+		return string(gif.FileUniqueID)
+	}
+
+	return string(gif.FileID)
+}
+
+// gifKey returns the search index document id of the gif with id in pack.
+func gifKey(packName, id string) string {
+	return fmt.Sprintf("%s:%s", strings.ToUpper(packName), id)
+}
+
+// HasEditPermissions returns true if userID can edit and delete gifs in pack.
+func HasEditPermissions(pack Pack, userID int) bool {
+	return Can(pack, userID, ActionEditGif)
 }
 
 // NewGif adds a new gif to pack. Returns true if a new gif was added to the pack, false if that gif was already in the
@@ -393,18 +567,18 @@ func NewGif(ctx context.Context, packName string, userID int, gif Gif) (bool, er
 	// normalise pack name
 	packName = strings.ToUpper(packName)
 
-	// check if user is the creator or a contributor to pack
+	// check if user can add gifs to pack
 	pack, err := GetPack(ctx, packName)
 	if err != nil {
 		return false, err
 	}
 
-	if !HasEditPermissions(pack, userID) {
+	if !Can(pack, userID, ActionAddGif) {
 		return false, ErrNotAllowed
 	}
 
 	// check if gif is already in pack
-	_, err = GetGif(ctx, packName, string(gif.FileID))
+	_, err = GetGif(ctx, packName, gifID(gif))
 	if err != nil {
 		if err != ErrNotFound {
 			return false, err
@@ -419,18 +593,38 @@ func NewGif(ctx context.Context, packName string, userID int, gif Gif) (bool, er
 		return false, err
 	}
 
-	key := fmt.Sprintf("%s:%s", packName, gif.FileID)
+	gif.Terms = keywordTerms(gif.Keywords)
+	gif.TitleTerms = keywordTerms(gif.Title)
+	gif.Added = time.Now()
+	key := gifKey(packName, gifID(gif))
 	_, err = index.Put(ctx, key, &gif)
 	if err != nil {
 		return false, err
 	}
 
+	err = updatePackCounts(ctx, packName, 1, 0)
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
 // EditGif updates a gif's keywords. Returns true if the gif existed and was updated, false if the gif did not exist in
 // pack.
 func EditGif(ctx context.Context, packName string, userID int, gif Gif) (bool, error) {
+	return updateGif(ctx, packName, userID, gif, ActionEditGif)
+}
+
+// AddGifDetails updates the title, caption and alt text of a gif userID has just added to pack, which only needs
+// permission to add gifs so that adders can finish adding their gifs. Returns true if the gif existed and was updated,
+// false if the gif did not exist in pack.
+func AddGifDetails(ctx context.Context, packName string, userID int, gif Gif) (bool, error) {
+	return updateGif(ctx, packName, userID, gif, ActionAddGif)
+}
+
+// updateGif replaces the gif in pack with gif if userID can perform action on pack.
+func updateGif(ctx context.Context, packName string, userID int, gif Gif, action Action) (bool, error) {
 	// validate pack name
 	if !packNameRegex.MatchString(packName) {
 		return false, ErrInvalidName
@@ -439,18 +633,18 @@ func EditGif(ctx context.Context, packName string, userID int, gif Gif) (bool, e
 	// normalise pack name
 	packName = strings.ToUpper(packName)
 
-	// check if user is the creator or a contributor to pack
+	// check if user can update gifs in pack
 	pack, err := GetPack(ctx, packName)
 	if err != nil {
 		return false, err
 	}
 
-	if !HasEditPermissions(pack, userID) {
+	if !Can(pack, userID, action) {
 		return false, ErrNotAllowed
 	}
 
 	// check if gif is already in pack
-	_, err = GetGif(ctx, packName, string(gif.FileID))
+	existing, err := GetGif(ctx, packName, gifID(gif))
 	if err != nil {
 		if err == ErrNotFound {
 			return false, nil
@@ -459,13 +653,21 @@ func EditGif(ctx context.Context, packName string, userID int, gif Gif) (bool, e
 		return false, err
 	}
 
+	// keep the media type of the gif if it was not given
+	if gif.Type == "" {
+		gif.Type = existing.Type
+	}
+	gif.Added = existing.Added
+
 	// update gif
 	index, err := search.Open(gifsIndex)
 	if err != nil {
 		return false, err
 	}
 
-	key := fmt.Sprintf("%s:%s", packName, gif.FileID)
+	gif.Terms = keywordTerms(gif.Keywords)
+	gif.TitleTerms = keywordTerms(gif.Title)
+	key := gifKey(packName, gifID(gif))
 	_, err = index.Put(ctx, key, &gif)
 	if err != nil {
 		return false, err
@@ -476,7 +678,7 @@ func EditGif(ctx context.Context, packName string, userID int, gif Gif) (bool, e
 
 // DeleteGif removes a gif from pack. Returns true if the the gif was deleted from the pack, false if the gif was not
 // part of the pack.
-func DeleteGif(ctx context.Context, packName string, userID int, fileID string) (bool, error) {
+func DeleteGif(ctx context.Context, packName string, userID int, id string) (bool, error) {
 	// validate pack name
 	if !packNameRegex.MatchString(packName) {
 		return false, ErrInvalidName
@@ -485,13 +687,13 @@ func DeleteGif(ctx context.Context, packName string, userID int, fileID string)
 	// normalise pack name
 	packName = strings.ToUpper(packName)
 
-	// check that user is the creator of pack
+	// check that user can delete gifs from pack
 	pack, err := GetPack(ctx, packName)
 	if err != nil {
 		return false, err
 	}
 
-	if !HasEditPermissions(pack, userID) {
+	if !Can(pack, userID, ActionDeleteGif) {
 		return false, ErrNotAllowed
 	}
 
@@ -502,7 +704,7 @@ func DeleteGif(ctx context.Context, packName string, userID int, fileID string)
 	}
 
 	var g Gif
-	key := fmt.Sprintf("%s:%s", packName, fileID)
+	key := gifKey(packName, id)
 	err = index.Get(ctx, key, &g)
 	if err != nil {
 		if err == search.ErrNoSuchDocument {
@@ -517,6 +719,11 @@ func DeleteGif(ctx context.Context, packName string, userID int, fileID string)
 		return false, err
 	}
 
+	err = updatePackCounts(ctx, packName, -1, 0)
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
@@ -530,7 +737,10 @@ func DeleteGif(ctx context.Context, packName string, userID int, fileID string)
 // packs that user is subscribed to.
 //
 // If there is no pack called pack-name, SearchGifs will return no results.
-// If <keywords> is provided, SearchGifs will filter the gifs it returns to only those containing <keywords>.
+// If <keywords> is provided, SearchGifs will filter the gifs it returns to only those containing <keywords> in their
+// keywords or title. Gifs containing <keywords> exactly come first, followed by gifs with a keyword starting with the
+// last word, if it is unfinished, or a keyword within a few typos of one of <keywords>. Within each group, gifs are
+// ranked by relevance and then by how recently they were added.
 func SearchGifs(ctx context.Context, user int, query string) ([]Gif, error) {
 	// default to searching all subscribed packs
 	if query == "" {
@@ -540,12 +750,15 @@ func SearchGifs(ctx context.Context, user int, query string) ([]Gif, error) {
 	split := collapseWhitespaceRegex.Split(query, -1)
 	packName := split[0]
 	var keywords []string
+	var prefix string
 	if len(split) > 1 {
-		keywords = split[1:]
+		keywords = normaliseKeywords(strings.Join(split[1:], " "), keywordOptions())
+		prefix = queryPrefix(strings.Join(split[1:], " "))
 	}
 
 	var packs []string
 	var results []Gif
+	synonyms := make(map[string][]string)
 	if packName == "-" {
 		// get all packs user is subscribed to
 		q := datastore.NewQuery(subscriptionKind).Filter("UserID =", user)
@@ -556,32 +769,63 @@ func SearchGifs(ctx context.Context, user int, query string) ([]Gif, error) {
 			return nil, err
 		}
 
-		// if the user is not subscribed to any packs, return an empty slice and no error
-		if len(subscriptions) == 0 {
-			return nil, nil
+		// load all subscribed packs at once
+		keys := make([]*datastore.Key, len(subscriptions))
+		for i, s := range subscriptions {
+			keys[i] = datastore.NewKey(ctx, packKind, strings.ToUpper(s.Pack), 0, nil)
+		}
+
+		subscribed := make([]Pack, len(subscriptions))
+		err = datastore.GetMulti(ctx, keys, subscribed)
+		errs, isMulti := err.(appengine.MultiError)
+		if err != nil && !isMulti {
+			return nil, err
 		}
 
-		for _, s := range subscriptions {
-			packName := strings.ToUpper(s.Pack)
+		for i, pack := range subscribed {
+			if isMulti && errs[i] != nil {
+				// leave out packs which no longer exist
+				if errs[i] == datastore.ErrNoSuchEntity {
+					continue
+				}
+
+				return nil, errs[i]
+			}
+
+			// leave out packs which have been deleted or made private since the user subscribed
+			if pack.Deleted || !Can(pack, user, ActionView) {
+				continue
+			}
+
+			packName := keys[i].StringID()
 			packs = append(packs, packName)
+			synonyms[packName] = pack.Synonyms
+		}
+
+		// if the user is not subscribed to any packs, return an empty slice and no error
+		if len(packs) == 0 {
+			return nil, nil
 		}
 	} else {
-		// check if pack exists
-		_, err := GetPack(ctx, packName)
+		// check if pack exists and user can see it
+		pack, err := GetPack(ctx, packName)
 		if err != nil {
 			// todo: return an InlineQueryResultArticle with the error
-			if err == ErrInvalidName {
-				return nil, nil
-			} else if err == datastore.ErrNoSuchEntity {
+			if err == ErrInvalidName || err == ErrNotFound || err == ErrDeleted {
 				return nil, nil
 			}
 
 			return nil, err
 		}
 
+		if !Can(pack, user, ActionView) {
+			return nil, nil
+		}
+
 		// normalise pack name
 		packName := strings.ToUpper(packName)
 		packs = []string{packName}
+		synonyms[packName] = pack.Synonyms
 	}
 
 	// open gifs index
@@ -591,22 +835,18 @@ func SearchGifs(ctx context.Context, user int, query string) ([]Gif, error) {
 	}
 
 	// search for matching gifs in each pack
-	var q, packValues string
-	if len(packs) > 1 {
-		packValues = fmt.Sprintf("(%s)", strings.Join(packs, " OR "))
-	} else {
-		packValues = packs[0]
-	}
-
+	terms := packTerms(packs, keywords, synonyms)
+	var q string
 	if len(keywords) > 0 {
-		q = fmt.Sprintf("Pack = %s AND Keywords = (%s)", packValues, strings.Join(keywords, " OR "))
+		q = searchGifsQuery(packs, terms)
 	} else {
-		q = fmt.Sprintf("Pack = %s", packValues)
+		q = fmt.Sprintf("Pack = %s", packsQuery(packs))
 	}
 
+	found := make(map[string]bool)
 	for t := gIndex.Search(ctx, q, nil); ; {
 		var gif Gif
-		_, err := t.Next(&gif)
+		key, err := t.Next(&gif)
 		if err != nil {
 			if err == search.Done {
 				break
@@ -615,12 +855,125 @@ func SearchGifs(ctx context.Context, user int, query string) ([]Gif, error) {
 			}
 		}
 
+		found[key] = true
 		results = append(results, gif)
 	}
 
+	rankGifs(results, terms)
+
+	// look for prefix and typo matches after the exact matches, as long as there is room for them
+	if (len(keywords) > 0 || prefix != "") && len(results) < maxInlineResults {
+		fuzzy, err := fuzzySearchGifs(ctx, gIndex, packs, terms, prefix, found)
+		if err != nil {
+			return nil, err
+		}
+
+		rankGifs(fuzzy, terms)
+		results = append(results, fuzzy...)
+	}
+
 	return results, nil
 }
 
+// packsQuery returns the search query value matching any of packs.
+func packsQuery(packs []string) string {
+	if len(packs) > 1 {
+		return fmt.Sprintf("(%s)", strings.Join(packs, " OR "))
+	}
+
+	return packs[0]
+}
+
+// packTerms returns keywords expanded with the synonyms of each of packs, keyed by pack name.
+func packTerms(packs []string, keywords []string, synonyms map[string][]string) map[string][]string {
+	opts := keywordOptions()
+	terms := make(map[string][]string)
+	for _, packName := range packs {
+		terms[packName] = expandSynonyms(keywords, synonyms[packName], opts)
+	}
+
+	return terms
+}
+
+// searchGifsQuery returns the search query for gifs in packs with keywords or a title matching any of the terms for
+// their pack. Packs with the same terms share a clause to keep the query short.
+func searchGifsQuery(packs []string, terms map[string][]string) string {
+	var order []string
+	packsByTerms := make(map[string][]string)
+	for _, packName := range packs {
+		quoted := strings.Join(quoteTerms(terms[packName]), " OR ")
+		if _, ok := packsByTerms[quoted]; !ok {
+			order = append(order, quoted)
+		}
+		packsByTerms[quoted] = append(packsByTerms[quoted], packName)
+	}
+
+	if len(order) == 1 {
+		return fmt.Sprintf("Pack = %s AND (Terms = (%s) OR TitleTerms = (%s))", packsQuery(packs), order[0], order[0])
+	}
+
+	var clauses []string
+	for _, quoted := range order {
+		clauses = append(clauses, fmt.Sprintf("(Pack = %s AND (Terms = (%s) OR TitleTerms = (%s)))", packsQuery(packsByTerms[quoted]), quoted, quoted))
+	}
+
+	return strings.Join(clauses, " OR ")
+}
+
+// ListGifs returns a page of the gifs in pack, starting from page 0. more will be true if there are further pages. err
+// will be ErrNotFound if userID cannot see pack.
+func ListGifs(ctx context.Context, packName string, userID int, page int) (gifs []Gif, more bool, err error) {
+	// validate pack name
+	if !packNameRegex.MatchString(packName) {
+		return nil, false, ErrInvalidName
+	}
+
+	// normalise pack name
+	packName = strings.ToUpper(packName)
+
+	pack, err := GetPack(ctx, packName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !Can(pack, userID, ActionView) {
+		return nil, false, ErrNotFound
+	}
+
+	if page < 0 {
+		page = 0
+	}
+
+	index, err := search.Open(gifsIndex)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// fetch one extra gif to find out if there is a next page
+	opts := &search.SearchOptions{
+		Offset: page * listGifsPageSize,
+		Limit:  listGifsPageSize + 1,
+	}
+	for t := index.Search(ctx, "Pack = "+packName, opts); ; {
+		var gif Gif
+		_, err := t.Next(&gif)
+		if err == search.Done {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		gifs = append(gifs, gif)
+	}
+
+	if len(gifs) > listGifsPageSize {
+		return gifs[:listGifsPageSize], true, nil
+	}
+
+	return gifs, false, nil
+}
+
 // SoftDeletePack sets a pack as deleted but does not remove the data yet.
 func SoftDeletePack(ctx context.Context, packName string, userID int) error {
 	// validate pack name
@@ -631,13 +984,13 @@ func SoftDeletePack(ctx context.Context, packName string, userID int) error {
 	// normalise pack name
 	packName = strings.ToUpper(packName)
 
-	// check that user is the creator of pack
+	// check that user can delete pack
 	pack, err := GetPack(ctx, packName)
 	if err != nil {
 		return err
 	}
 
-	if pack.Creator != userID {
+	if !Can(pack, userID, ActionDeletePack) {
 		return ErrNotAllowed
 	}
 
@@ -660,13 +1013,13 @@ func DeletePack(ctx context.Context, packName string, userID int) (bool, error)
 	// normalise pack name
 	packName = strings.ToUpper(packName)
 
-	// check that user is the creator of pack
+	// check that user can delete pack
 	pack, err := GetPack(ctx, packName)
 	if err != nil {
 		return false, err
 	}
 
-	if pack.Creator != userID {
+	if !Can(pack, userID, ActionDeletePack) {
 		return false, ErrNotAllowed
 	}
 
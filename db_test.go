@@ -7,12 +7,14 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
 	"google.golang.org/appengine"
 	"google.golang.org/appengine/aetest"
 	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/search"
 )
 
 func sliceSameContents(s1, s2 []int) bool {
@@ -426,6 +428,28 @@ func TestSubscribe(t *testing.T) {
 		t.Fatalf("%v", err2)
 	}
 
+	private := Pack{
+		Name:       "private",
+		Creator:    1,
+		Viewers:    []int{3},
+		Visibility: VisibilityPrivate,
+	}
+
+	key3 := datastore.NewKey(ctx, packKind, strings.ToUpper(private.Name), 0, nil)
+	_, err3 := datastore.Put(ctx, key3, &private)
+	if err3 != nil {
+		t.Fatalf("%v", err3)
+	}
+
+	t.Run("private pack", func(t *testing.T) {
+		_, err := Subscribe(ctx, private.Name, 2)
+		assert.Equal(t, err, ErrNotFound)
+	})
+	t.Run("private pack member", func(t *testing.T) {
+		ok, err := Subscribe(ctx, private.Name, 3)
+		assert.Equal(t, err, nil)
+		assert.True(t, ok)
+	})
 	t.Run("nonexistent pack", func(t *testing.T) {
 		_, err := Subscribe(ctx, "pack2", 1)
 		if err != nil {
@@ -457,6 +481,13 @@ func TestSubscribe(t *testing.T) {
 		if !ok {
 			t.Fail()
 		}
+
+		pack, err := GetPack(ctx, pack1.Name)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		assert.Equal(t, pack.Subscribers, 1)
 	})
 }
 
@@ -596,3 +627,171 @@ func TestSoftDeletePack(t *testing.T) {
 		assert.True(t, pack.Deleted)
 	})
 }
+
+func TestSetMemberRole(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	pack1 := Pack{
+		Name:         "pack1",
+		Creator:      1,
+		Contributors: []int{2},
+	}
+
+	key := datastore.NewKey(ctx, packKind, strings.ToUpper(pack1.Name), 0, nil)
+	_, err = datastore.Put(ctx, key, &pack1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("not owner", func(t *testing.T) {
+		_, err := SetMemberRole(ctx, pack1.Name, 2, 3, RoleAdder)
+		assert.Equal(t, err, ErrNotAllowed)
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		_, err := SetMemberRole(ctx, pack1.Name, 1, 2, RoleOwner)
+		assert.Equal(t, err, ErrInvalidRole)
+
+		_, err = SetMemberRole(ctx, pack1.Name, 1, 1, RoleViewer)
+		assert.Equal(t, err, ErrNotAllowed)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		ok, err := SetMemberRole(ctx, pack1.Name, 1, 2, RoleEditor)
+		assert.Equal(t, err, nil)
+		assert.False(t, ok)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		ok, err := SetMemberRole(ctx, pack1.Name, 1, 2, RoleAdder)
+		assert.Equal(t, err, nil)
+		assert.True(t, ok)
+
+		ok, err = SetMemberRole(ctx, pack1.Name, 1, 3, RoleViewer)
+		assert.Equal(t, err, nil)
+		assert.True(t, ok)
+
+		pack, err := GetPack(ctx, pack1.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(t, pack.Contributors, 0)
+		assert.Equal(t, pack.Adders, []int{2})
+		assert.Equal(t, pack.Viewers, []int{3})
+	})
+}
+
+func TestSetPackVisibility(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	pack1 := Pack{
+		Name:         "pack1",
+		Creator:      1,
+		Contributors: []int{2},
+	}
+
+	key := datastore.NewKey(ctx, packKind, strings.ToUpper(pack1.Name), 0, nil)
+	_, err = datastore.Put(ctx, key, &pack1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("invalid visibility", func(t *testing.T) {
+		err := SetPackVisibility(ctx, pack1.Name, 1, "secret")
+		assert.Equal(t, err, ErrInvalidVisibility)
+	})
+
+	t.Run("not owner", func(t *testing.T) {
+		err := SetPackVisibility(ctx, pack1.Name, 2, VisibilityPrivate)
+		assert.Equal(t, err, ErrNotAllowed)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		err := SetPackVisibility(ctx, pack1.Name, 1, VisibilityPrivate)
+		assert.Equal(t, err, nil)
+
+		pack, err := GetPack(ctx, pack1.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, pack.Visibility, VisibilityPrivate)
+	})
+}
+
+func TestSearchGifsQuery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("one pack", func(t *testing.T) {
+		q := searchGifsQuery([]string{"A"}, map[string][]string{"A": {"laugh"}})
+		assert.Equal(t, q, `Pack = A AND (Terms = ("laugh") OR TitleTerms = ("laugh"))`)
+	})
+
+	t.Run("same terms", func(t *testing.T) {
+		q := searchGifsQuery([]string{"A", "B"}, map[string][]string{"A": {"laugh"}, "B": {"laugh"}})
+		assert.Equal(t, q, `Pack = (A OR B) AND (Terms = ("laugh") OR TitleTerms = ("laugh"))`)
+	})
+
+	t.Run("different terms", func(t *testing.T) {
+		terms := map[string][]string{
+			"A": {"laugh"},
+			"B": {"laugh", "lol"},
+			"C": {"laugh"},
+		}
+		q := searchGifsQuery([]string{"A", "B", "C"}, terms)
+		assert.Equal(t, q, `(Pack = (A OR C) AND (Terms = ("laugh") OR TitleTerms = ("laugh"))) OR (Pack = B AND (Terms = ("laugh" OR "lol") OR TitleTerms = ("laugh" OR "lol")))`)
+	})
+}
+
+func TestPackTerms(t *testing.T) {
+	t.Parallel()
+
+	terms := packTerms([]string{"A", "B"}, []string{"laugh"}, map[string][]string{"B": {"lol laugh"}})
+	assert.Equal(t, terms, map[string][]string{"A": {"laugh"}, "B": {"laugh", "lol"}})
+}
+
+func TestGifSave(t *testing.T) {
+	t.Parallel()
+
+	hasAdded := func(fields []search.Field) bool {
+		for _, field := range fields {
+			if field.Name == "Added" {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	t.Run("added", func(t *testing.T) {
+		gif := Gif{Keywords: "cat", Added: time.Now()}
+		fields, _, err := gif.Save()
+		assert.Equal(t, err, nil)
+		assert.True(t, hasAdded(fields))
+	})
+
+	t.Run("added before it was recorded", func(t *testing.T) {
+		gif := Gif{Keywords: "cat"}
+		fields, _, err := gif.Save()
+		assert.Equal(t, err, nil)
+		assert.False(t, hasAdded(fields))
+
+		var loaded Gif
+		err = loaded.Load(fields, nil)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, loaded, gif)
+	})
+}
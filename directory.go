@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// directory sort orders
+const (
+	SortPopular = "popular"
+	SortRecent  = "recent"
+)
+
+const (
+	// browsePageSize is the number of packs shown on each page of the pack directory
+	browsePageSize = 5
+
+	// findPacksLimit is the maximum number of packs returned by FindPacks
+	findPacksLimit = 10
+)
+
+var sortOrders = map[string]string{
+	SortPopular: "-Subscribers",
+	SortRecent:  "-Created",
+}
+
+// BrowsePacks returns a page of public packs sorted by sortBy, starting from page 0. more will be true if there are
+// further pages.
+func BrowsePacks(ctx context.Context, sortBy string, page int) (packs []Pack, more bool, err error) {
+	order, ok := sortOrders[sortBy]
+	if !ok {
+		order = sortOrders[SortPopular]
+	}
+
+	if page < 0 {
+		page = 0
+	}
+
+	// fetch one extra pack to find out if there is a next page
+	q := datastore.
+		NewQuery(packKind).
+		Filter("Visibility =", VisibilityPublic).
+		Filter("Deleted =", false).
+		Order(order).
+		Offset(page * browsePageSize).
+		Limit(browsePageSize + 1)
+	_, err = q.GetAll(ctx, &packs)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(packs) > browsePageSize {
+		return packs[:browsePageSize], true, nil
+	}
+
+	return packs, false, nil
+}
+
+// FindPacks returns public packs whose names contain text, ignoring case.
+func FindPacks(ctx context.Context, text string) ([]Pack, error) {
+	text = strings.ToUpper(strings.TrimSpace(text))
+
+	// pack keys are their normalised names, so matching names can be found without loading every pack
+	q := datastore.
+		NewQuery(packKind).
+		Filter("Visibility =", VisibilityPublic).
+		Filter("Deleted =", false).
+		KeysOnly()
+	keys, err := q.GetAll(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*datastore.Key
+	for _, key := range keys {
+		if strings.Contains(key.StringID(), text) {
+			matches = append(matches, key)
+			if len(matches) == findPacksLimit {
+				break
+			}
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	packs := make([]Pack, len(matches))
+	err = datastore.GetMulti(ctx, matches, packs)
+	if err != nil {
+		return nil, err
+	}
+
+	return packs, nil
+}
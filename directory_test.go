@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/aetest"
+	"google.golang.org/appengine/datastore"
+)
+
+func putDirectoryPacks(t *testing.T, ctx context.Context) {
+	now := time.Now()
+	packs := []Pack{
+		{
+			Name:        "cats",
+			Creator:     1,
+			Visibility:  VisibilityPublic,
+			Subscribers: 1,
+			Created:     now,
+		},
+		{
+			Name:        "dogs",
+			Creator:     1,
+			Visibility:  VisibilityPublic,
+			Subscribers: 3,
+			Created:     now.Add(-time.Hour),
+		},
+		{
+			Name:        "catsecret",
+			Creator:     1,
+			Visibility:  VisibilityPrivate,
+			Subscribers: 5,
+			Created:     now,
+		},
+		{
+			Name:        "catdeleted",
+			Creator:     1,
+			Visibility:  VisibilityPublic,
+			Subscribers: 5,
+			Created:     now,
+			Deleted:     true,
+		},
+	}
+
+	for _, pack := range packs {
+		key := datastore.NewKey(ctx, packKind, strings.ToUpper(pack.Name), 0, nil)
+		_, err := datastore.Put(ctx, key, &pack)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBrowsePacks(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := NewContext(&aetest.Options{
+		StronglyConsistentDatastore: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	putDirectoryPacks(t, ctx)
+
+	t.Run("popular", func(t *testing.T) {
+		packs, more, err := BrowsePacks(ctx, SortPopular, 0)
+		assert.Equal(t, err, nil)
+		assert.False(t, more)
+		if assert.Len(t, packs, 2) {
+			assert.Equal(t, packs[0].Name, "dogs")
+			assert.Equal(t, packs[1].Name, "cats")
+		}
+	})
+
+	t.Run("recent", func(t *testing.T) {
+		packs, _, err := BrowsePacks(ctx, SortRecent, 0)
+		assert.Equal(t, err, nil)
+		if assert.Len(t, packs, 2) {
+			assert.Equal(t, packs[0].Name, "cats")
+			assert.Equal(t, packs[1].Name, "dogs")
+		}
+	})
+
+	t.Run("past last page", func(t *testing.T) {
+		packs, more, err := BrowsePacks(ctx, SortPopular, 1)
+		assert.Equal(t, err, nil)
+		assert.False(t, more)
+		assert.Len(t, packs, 0)
+	})
+}
+
+func TestFindPacks(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := NewContext(&aetest.Options{
+		StronglyConsistentDatastore: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	putDirectoryPacks(t, ctx)
+
+	t.Run("no matches", func(t *testing.T) {
+		packs, err := FindPacks(ctx, "birds")
+		assert.Equal(t, err, nil)
+		assert.Len(t, packs, 0)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		packs, err := FindPacks(ctx, "Cat")
+		assert.Equal(t, err, nil)
+		if assert.Len(t, packs, 1) {
+			assert.Equal(t, packs[0].Name, "cats")
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+// emojiAliases are the words an emoji stands for, so that searching for an emoji finds gifs tagged with those words
+// and the other way round.
+var emojiAliases = map[string][]string{
+	"😂": {"laugh", "lol"},
+	"🤣": {"laugh", "lol"},
+	"😆": {"laugh"},
+	"😄": {"happy", "smile"},
+	"😊": {"happy", "smile"},
+	"🙂": {"smile"},
+	"😍": {"love"},
+	"🥰": {"love"},
+	"❤": {"love", "heart"},
+	"💔": {"heartbreak", "sad"},
+	"😢": {"sad", "cry"},
+	"😭": {"sad", "cry"},
+	"😡": {"angry"},
+	"😠": {"angry"},
+	"😱": {"scared", "shock"},
+	"😮": {"surprise", "wow"},
+	"🤔": {"think", "hmm"},
+	"🙄": {"eyeroll", "whatever"},
+	"😴": {"sleep", "tired"},
+	"🥳": {"party", "celebrate"},
+	"🎉": {"party", "celebrate"},
+	"👍": {"yes", "ok", "thumbsup"},
+	"👎": {"no", "thumbsdown"},
+	"👏": {"clap", "applause"},
+	"🙏": {"please", "thanks"},
+	"👋": {"hello", "bye", "wave"},
+	"💃": {"dance"},
+	"🕺": {"dance"},
+	"🔥": {"fire", "lit"},
+	"💯": {"perfect", "hundred"},
+	"🐱": {"cat"},
+	"🐈": {"cat"},
+	"🐶": {"dog"},
+	"🐕": {"dog"},
+	"🤦": {"facepalm"},
+	"🤷": {"shrug", "whatever"},
+}
+
+// isEmoji returns true if r is an emoji, judging by the Unicode blocks which hold them.
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF:
+		// emoticons, pictographs, transport and other supplemental symbols
+		return true
+	case r >= 0x2600 && r <= 0x27BF:
+		// miscellaneous symbols and dingbats
+		return true
+	case r >= 0x2300 && r <= 0x23FF, r >= 0x2B00 && r <= 0x2BFF:
+		// miscellaneous technical symbols like ⌛ and arrows and stars like ⭐
+		return true
+	}
+
+	return false
+}
+
+// isEmojiModifier returns true if r only changes how the emoji before it looks, like a skin tone, or joins emoji
+// together. Modifiers are dropped so that an emoji matches itself whatever its skin tone.
+func isEmojiModifier(r rune) bool {
+	switch {
+	case r >= 0x1F3FB && r <= 0x1F3FF:
+		// skin tones
+		return true
+	case r == 0xFE0E, r == 0xFE0F:
+		// text and emoji presentation selectors
+		return true
+	case r == 0x200D:
+		// zero width joiner
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsEmoji(t *testing.T) {
+	t.Parallel()
+
+	for _, r := range "😂❤⭐⌛🐱🎉" {
+		assert.Equal(t, isEmoji(r), true, string(r))
+	}
+
+	for _, r := range "a1猫,é€" {
+		assert.Equal(t, isEmoji(r), false, string(r))
+	}
+}
+
+func TestEmojiAliases(t *testing.T) {
+	t.Parallel()
+
+	// aliases are looked up by the tokens tokenise produces, so every emoji in the table must be a single token
+	for emoji := range emojiAliases {
+		assert.Equal(t, tokenise(emoji), []string{emoji}, emoji)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/search"
+)
+
+const (
+	// maxInlineResults is the most results Telegram shows for an inline query. Fuzzy matches are only looked for when
+	// there are fewer exact matches than this.
+	maxInlineResults = 50
+
+	// fuzzySearchLimit is the maximum number of gifs checked for fuzzy matches, since they are matched one by one
+	fuzzySearchLimit = 300
+
+	// minPrefixLength is the shortest unfinished word which is matched as a prefix
+	minPrefixLength = 2
+)
+
+// queryPrefix returns the last word of keywords if it looks unfinished, which it does unless keywords ends with a
+// space. Inline queries are sent on every keystroke, so the last word is usually still being typed.
+func queryPrefix(keywords string) string {
+	if keywords == "" {
+		return ""
+	}
+
+	if last, _ := utf8.DecodeLastRuneInString(keywords); unicode.IsSpace(last) {
+		return ""
+	}
+
+	tokens := tokenise(keywords)
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	prefix := tokens[len(tokens)-1]
+	if utf8.RuneCountInString(prefix) < minPrefixLength {
+		return ""
+	}
+
+	return prefix
+}
+
+// maxTypos returns the number of typos allowed when matching term, which grows with its length so that short words
+// don't match everything.
+func maxTypos(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+
+	return 0
+}
+
+// editDistance returns the Levenshtein distance between a and b, which is the number of single character insertions,
+// deletions and substitutions needed to turn one into the other.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(t)]
+}
+
+// fuzzyMatch returns true if gif has a word in its keywords or title starting with prefix, or a term within a few typos
+// of one of terms. terms must already be normalised.
+func fuzzyMatch(gif Gif, terms []string, prefix string) bool {
+	if prefix != "" {
+		for _, token := range tokenise(gif.Keywords + " " + gif.Title) {
+			if strings.HasPrefix(token, prefix) {
+				return true
+			}
+		}
+	}
+
+	for _, gifTerm := range strings.Fields(gif.Terms + " " + gif.TitleTerms) {
+		for _, term := range terms {
+			if typos := maxTypos(term); typos > 0 && editDistance(term, gifTerm) <= typos {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// fuzzySearchGifs returns the gifs in packs which fuzzily match the terms for their pack or prefix. Gifs whose keys
+// are in exclude, which have already been found by an exact search, are left out. Only the fields needed for matching
+// are loaded while checking gifs, and the whole gif is only loaded for matches.
+func fuzzySearchGifs(ctx context.Context, index *search.Index, packs []string, terms map[string][]string, prefix string, exclude map[string]bool) ([]Gif, error) {
+	var keys []string
+	q := "Pack = " + packsQuery(packs)
+	opts := &search.SearchOptions{
+		Limit:  fuzzySearchLimit,
+		Fields: []string{"Pack", "Keywords", "Title", "Terms", "TitleTerms"},
+	}
+	for t := index.Search(ctx, q, opts); ; {
+		var gif Gif
+		key, err := t.Next(&gif)
+		if err == search.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if exclude[key] {
+			continue
+		}
+
+		if fuzzyMatch(gif, terms[strings.ToUpper(string(gif.Pack))], prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	gifs := make([]Gif, 0, len(keys))
+	for _, key := range keys {
+		var gif Gif
+		err := index.Get(ctx, key, &gif)
+		if err != nil {
+			return nil, err
+		}
+
+		gifs = append(gifs, gif)
+	}
+
+	return gifs, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryPrefix(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]string{
+		"":          "",
+		"cats hap":  "hap",
+		"cats Hap":  "hap",
+		"cats hap ": "",
+		"cats h":    "",
+		"cats hap!": "hap",
+	}
+
+	for keywords, expected := range testCases {
+		assert.Equal(t, queryPrefix(keywords), expected, keywords)
+	}
+}
+
+func TestMaxTypos(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, maxTypos("cat"), 0)
+	assert.Equal(t, maxTypos("danc"), 1)
+	assert.Equal(t, maxTypos("surprise"), 2)
+	assert.Equal(t, maxTypos("猫咪"), 0)
+}
+
+func TestEditDistance(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"cat", "", 3},
+		{"cat", "cat", 0},
+		{"cat", "cart", 1},
+		{"kitten", "sitting", 3},
+		{"hapy", "happi", 2},
+		{"猫咪", "猫", 1},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, editDistance(tc.a, tc.b), tc.distance, tc.a+" "+tc.b)
+		assert.Equal(t, editDistance(tc.b, tc.a), tc.distance, tc.b+" "+tc.a)
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	t.Parallel()
+
+	gif := Gif{Keywords: "Happy dancing", Terms: "happi danc"}
+
+	testCases := []struct {
+		name   string
+		terms  []string
+		prefix string
+		match  bool
+	}{
+		{"prefix", []string{"hap"}, "hap", true},
+		{"prefix of unstemmed keyword", []string{"dancin"}, "dancin", true},
+		{"typo", []string{"hapi"}, "", true},
+		{"too many typos", []string{"hopo"}, "", false},
+		{"short words need to be exact", []string{"dan"}, "", false},
+		{"no match", []string{"sad"}, "sa", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, fuzzyMatch(gif, tc.terms, tc.prefix), tc.match)
+		})
+	}
+}
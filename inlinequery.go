@@ -12,23 +12,60 @@ func HandleInlineQuery(ctx context.Context, bot *tgbotapi.BotAPI, inlineQuery *t
 	userID := inlineQuery.From.ID
 	query := inlineQuery.Query
 
+	if packName, ok := parseKeywordsQuery(query); ok {
+		handleKeywordsQuery(ctx, bot, inlineQueryID, userID, packName)
+		return
+	}
+
 	gifs, err := SearchGifs(ctx, userID, query)
 	if err != nil {
 		log.Errorf(ctx, "%v", err)
 	}
 
+	config := tgbotapi.InlineConfig{
+		InlineQueryID: inlineQueryID,
+		Results:       inlineQueryResults(gifs),
+		IsPersonal:    true,
+	}
+
+	resp, err := bot.AnswerInlineQuery(config)
+	if err != nil {
+		log.Errorf(ctx, "%v", resp)
+		log.Errorf(ctx, "%v", err)
+	}
+}
+
+// inlineQueryResults returns the inline results for gifs in the same order, leaving out all but the first and most
+// relevant copy of gifs which are in several packs. Only as many results as Telegram shows are returned.
+func inlineQueryResults(gifs []Gif) []interface{} {
 	results := make([]interface{}, 0)
-	if len(gifs) > 0 {
-		// deduplicate results
-		gifsMap := make(map[string]int)
-		for _, gif := range gifs {
-			gifsMap[string(gif.FileID)] = 0
+	seen := make(map[string]bool)
+	for _, gif := range gifs {
+		id := gifID(gif)
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+		results = append(results, inlineQueryResult(id, gif))
+		if len(results) == maxInlineResults {
+			break
 		}
+	}
+
+	return results
+}
 
-		for fileID := range gifsMap {
-			id := string(fileID)
-			results = append(results, NewInlineQueryResultCachedMpeg4Gif(id, id))
+// handleKeywordsQuery answers an inline query like "pack_name ?" with the keywords used in the pack.
+func handleKeywordsQuery(ctx context.Context, bot *tgbotapi.BotAPI, inlineQueryID string, userID int, packName string) {
+	results := make([]interface{}, 0)
+	keywords, err := PackKeywords(ctx, packName, userID)
+	if err != nil {
+		if err != ErrInvalidName && err != ErrNotFound && err != ErrDeleted {
+			log.Errorf(ctx, "%v", err)
 		}
+	} else {
+		results = keywordArticles(packName, keywords)
 	}
 
 	config := tgbotapi.InlineConfig{
@@ -43,3 +80,52 @@ func HandleInlineQuery(ctx context.Context, bot *tgbotapi.BotAPI, inlineQuery *t
 		log.Errorf(ctx, "%v", err)
 	}
 }
+
+// inlineQueryResult returns the cached inline query result matching the media type of gif, with its title and caption.
+// Gifs without a title use their alt text instead, and videos and voice notes, which need a title, fall back to their
+// keywords.
+func inlineQueryResult(id string, gif Gif) interface{} {
+	fileID := string(gif.FileID)
+	title := gif.Title
+	if title == "" {
+		title = gif.AltText
+	}
+
+	switch gifMediaType(gif) {
+	case MediaTypeGif:
+		result := NewInlineQueryResultCachedGif(id, fileID)
+		result.Title = title
+		result.Caption = gif.Caption
+		return result
+	case MediaTypeSticker:
+		return NewInlineQueryResultCachedSticker(id, fileID)
+	case MediaTypePhoto:
+		result := NewInlineQueryResultCachedPhoto(id, fileID)
+		result.Title = title
+		result.Description = gif.AltText
+		result.Caption = gif.Caption
+		return result
+	case MediaTypeVideo:
+		if title == "" {
+			title = gif.Keywords
+		}
+
+		result := NewInlineQueryResultCachedVideo(id, fileID, title)
+		result.Description = gif.AltText
+		result.Caption = gif.Caption
+		return result
+	case MediaTypeVoice:
+		if title == "" {
+			title = gif.Keywords
+		}
+
+		result := NewInlineQueryResultCachedVoice(id, fileID, title)
+		result.Caption = gif.Caption
+		return result
+	default:
+		result := NewInlineQueryResultCachedMpeg4Gif(id, fileID)
+		result.Title = title
+		result.Caption = gif.Caption
+		return result
+	}
+}
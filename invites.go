@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// app engine datastore kinds
+const (
+	inviteKind = "Invite"
+)
+
+const (
+	// inviteTTL is how long an invite remains valid after it is created
+	inviteTTL = 7 * 24 * time.Hour
+
+	// invitePayloadPrefix is prepended to invite tokens in deep link start payloads
+	invitePayloadPrefix = "invite-"
+)
+
+// invite errors
+var (
+	ErrInviteInvalid = errors.New("invite invalid or expired")
+)
+
+// Invite represents a one-time invitation to become a member of a gif pack in datastore
+type Invite struct {
+	Token   string
+	Pack    string
+	Role    Role
+	Creator int
+	Created time.Time
+	Expires time.Time
+}
+
+// Expired returns true if invite can no longer be redeemed at time now.
+func (invite Invite) Expired(now time.Time) bool {
+	return !now.Before(invite.Expires)
+}
+
+func newInviteToken() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// NewInvite creates a new invite to pack which will give whoever redeems it role. Only users who can manage the
+// members of pack can create invites.
+func NewInvite(ctx context.Context, packName string, creator int, role Role) (Invite, error) {
+	// validate pack name
+	if !packNameRegex.MatchString(packName) {
+		return Invite{}, ErrInvalidName
+	}
+
+	if role == RoleNone || role == RoleOwner {
+		return Invite{}, ErrInvalidRole
+	}
+
+	// normalise pack name
+	packName = strings.ToUpper(packName)
+
+	// check that pack exists and creator can manage its members
+	pack, err := GetPack(ctx, packName)
+	if err != nil {
+		return Invite{}, err
+	}
+
+	if !Can(pack, creator, ActionManageMembers) {
+		return Invite{}, ErrNotAllowed
+	}
+
+	token, err := newInviteToken()
+	if err != nil {
+		return Invite{}, err
+	}
+
+	now := time.Now()
+	invite := Invite{
+		Token:   token,
+		Pack:    packName,
+		Role:    role,
+		Creator: creator,
+		Created: now,
+		Expires: now.Add(inviteTTL),
+	}
+
+	key := datastore.NewKey(ctx, inviteKind, token, 0, nil)
+	_, err = datastore.Put(ctx, key, &invite)
+	if err != nil {
+		return Invite{}, err
+	}
+
+	return invite, nil
+}
+
+// GetInvites returns the outstanding invites to pack. Only users who can manage the members of pack can list invites.
+func GetInvites(ctx context.Context, packName string, creator int) ([]Invite, error) {
+	// validate pack name
+	if !packNameRegex.MatchString(packName) {
+		return nil, ErrInvalidName
+	}
+
+	// normalise pack name
+	packName = strings.ToUpper(packName)
+
+	// check that pack exists and creator can manage its members
+	pack, err := GetPack(ctx, packName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !Can(pack, creator, ActionManageMembers) {
+		return nil, ErrNotAllowed
+	}
+
+	var invites []Invite
+	q := datastore.NewQuery(inviteKind).Filter("Pack =", packName)
+	_, err = q.GetAll(ctx, &invites)
+	if err != nil {
+		return nil, err
+	}
+
+	// leave out invites which have expired but have not been cleaned up
+	now := time.Now()
+	var outstanding []Invite
+	for _, invite := range invites {
+		if invite.Expired(now) {
+			continue
+		}
+		outstanding = append(outstanding, invite)
+	}
+
+	return outstanding, nil
+}
+
+// RedeemInvite gives userID the role in the pack invite token was created for and uses up the invite. Returns the name
+// of the pack and true if userID was added, false if userID already had that role or a higher one, in which case the
+// invite is left unused. err will be ErrInviteInvalid if the invite does not exist or has expired.
+func RedeemInvite(ctx context.Context, token string, userID int) (string, bool, error) {
+	var packName string
+	var added bool
+	err := datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		key := datastore.NewKey(tc, inviteKind, token, 0, nil)
+		var invite Invite
+		err := datastore.Get(tc, key, &invite)
+		if err != nil {
+			if err == datastore.ErrNoSuchEntity {
+				return ErrInviteInvalid
+			}
+
+			return err
+		}
+
+		if invite.Expired(time.Now()) {
+			return ErrInviteInvalid
+		}
+
+		// invites created before roles were introduced always made editors
+		if invite.Role == RoleNone {
+			invite.Role = RoleEditor
+		}
+
+		pack, err := GetPack(tc, invite.Pack)
+		if err != nil {
+			return err
+		}
+
+		packName = pack.Name
+		if PackRole(pack, userID) >= invite.Role {
+			added = false
+			return nil
+		}
+
+		// the pack may have changed owners since the invite was created
+		added, err = SetMemberRole(tc, invite.Pack, pack.Creator, userID, invite.Role)
+		if err != nil {
+			return err
+		}
+
+		// invites can only be used once
+		return datastore.Delete(tc, key)
+	}, &datastore.TransactionOptions{XG: true})
+	if err != nil {
+		return "", false, err
+	}
+
+	return packName, added, nil
+}
+
+// RevokeInvite deletes an outstanding invite. Returns true if the invite was revoked, false if there was no such
+// invite. Only users who can manage the members of the pack the invite is for can revoke it.
+func RevokeInvite(ctx context.Context, token string, creator int) (bool, error) {
+	key := datastore.NewKey(ctx, inviteKind, token, 0, nil)
+	var invite Invite
+	err := datastore.Get(ctx, key, &invite)
+	if err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	pack, err := GetPack(ctx, invite.Pack)
+	if err != nil && err != ErrDeleted {
+		return false, err
+	}
+
+	if !Can(pack, creator, ActionManageMembers) {
+		return false, ErrNotAllowed
+	}
+
+	err = datastore.Delete(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
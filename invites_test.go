@@ -0,0 +1,211 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/appengine/aetest"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestNewInvite(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	pack1 := Pack{
+		Name:    "pack1",
+		Creator: 1,
+	}
+
+	key := datastore.NewKey(ctx, packKind, strings.ToUpper(pack1.Name), 0, nil)
+	_, err = datastore.Put(ctx, key, &pack1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("not creator", func(t *testing.T) {
+		_, err := NewInvite(ctx, pack1.Name, 2, RoleEditor)
+		assert.Equal(t, err, ErrNotAllowed)
+	})
+
+	t.Run("invalid role", func(t *testing.T) {
+		_, err := NewInvite(ctx, pack1.Name, 1, RoleOwner)
+		assert.Equal(t, err, ErrInvalidRole)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		invite, err := NewInvite(ctx, pack1.Name, 1, RoleAdder)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, invite.Pack, "PACK1")
+		assert.Equal(t, invite.Role, RoleAdder)
+		assert.Equal(t, invite.Creator, 1)
+		assert.Len(t, invite.Token, 32)
+		assert.True(t, invite.Expires.After(time.Now()))
+	})
+}
+
+func TestRedeemInvite(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := NewContext(&aetest.Options{
+		StronglyConsistentDatastore: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	pack1 := Pack{
+		Name:         "pack1",
+		Creator:      1,
+		Contributors: []int{2},
+	}
+
+	now := time.Now()
+	invites := []Invite{
+		{
+			Token:   "valid",
+			Pack:    "PACK1",
+			Role:    RoleEditor,
+			Creator: 1,
+			Created: now,
+			Expires: now.Add(time.Hour),
+		},
+		{
+			Token:   "expired",
+			Pack:    "PACK1",
+			Role:    RoleEditor,
+			Creator: 1,
+			Created: now.Add(-2 * time.Hour),
+			Expires: now.Add(-time.Hour),
+		},
+		{
+			Token:   "legacy",
+			Pack:    "PACK1",
+			Creator: 1,
+			Created: now,
+			Expires: now.Add(time.Hour),
+		},
+	}
+
+	key := datastore.NewKey(ctx, packKind, strings.ToUpper(pack1.Name), 0, nil)
+	_, err = datastore.Put(ctx, key, &pack1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, invite := range invites {
+		key := datastore.NewKey(ctx, inviteKind, invite.Token, 0, nil)
+		_, err := datastore.Put(ctx, key, &invite)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("nonexistent invite", func(t *testing.T) {
+		_, _, err := RedeemInvite(ctx, "nonexistent", 3)
+		assert.Equal(t, err, ErrInviteInvalid)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		_, _, err := RedeemInvite(ctx, "expired", 3)
+		assert.Equal(t, err, ErrInviteInvalid)
+	})
+
+	t.Run("already a contributor", func(t *testing.T) {
+		packName, ok, err := RedeemInvite(ctx, "valid", 2)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, packName, pack1.Name)
+		assert.False(t, ok)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		packName, ok, err := RedeemInvite(ctx, "valid", 3)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, packName, pack1.Name)
+		assert.True(t, ok)
+
+		pack, err := GetPack(ctx, pack1.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, sliceSameContents([]int{2, 3}, pack.Contributors))
+
+		// invites can only be used once
+		_, _, err = RedeemInvite(ctx, "valid", 4)
+		assert.Equal(t, err, ErrInviteInvalid)
+	})
+
+	t.Run("invite without a role", func(t *testing.T) {
+		_, ok, err := RedeemInvite(ctx, "legacy", 5)
+		assert.Equal(t, err, nil)
+		assert.True(t, ok)
+
+		pack, err := GetPack(ctx, pack1.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, PackRole(pack, 5), RoleEditor)
+	})
+}
+
+func TestRevokeInvite(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := NewContext(&aetest.Options{
+		StronglyConsistentDatastore: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	pack1 := Pack{
+		Name:    "pack1",
+		Creator: 1,
+	}
+
+	key := datastore.NewKey(ctx, packKind, strings.ToUpper(pack1.Name), 0, nil)
+	_, err = datastore.Put(ctx, key, &pack1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invite, err := NewInvite(ctx, pack1.Name, 1, RoleEditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("not creator", func(t *testing.T) {
+		_, err := RevokeInvite(ctx, invite.Token, 2)
+		assert.Equal(t, err, ErrNotAllowed)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		ok, err := RevokeInvite(ctx, invite.Token, 1)
+		assert.Equal(t, err, nil)
+		assert.True(t, ok)
+
+		invites, err := GetInvites(ctx, pack1.Name, 1)
+		assert.Equal(t, err, nil)
+		assert.Len(t, invites, 0)
+	})
+
+	t.Run("nonexistent invite", func(t *testing.T) {
+		ok, err := RevokeInvite(ctx, invite.Token, 1)
+		assert.Equal(t, err, nil)
+		assert.False(t, ok)
+	})
+}
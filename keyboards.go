@@ -0,0 +1,427 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"golang.org/x/net/context"
+)
+
+const (
+	// maxCallbackDataLength is the maximum length of callback data allowed by Telegram
+	maxCallbackDataLength = 64
+
+	// subscribePayloadPrefix is prepended to pack names in deep link start payloads which subscribe to a pack
+	subscribePayloadPrefix = "subscribe-"
+)
+
+// packSummary returns a one line description of a public pack.
+func packSummary(pack Pack) string {
+	return fmt.Sprintf("%s: %d gifs, %d subscribers", pack.Name, pack.Gifs, pack.Subscribers)
+}
+
+// subscribeButtons returns a row with a Subscribe button for each pack.
+func subscribeButtons(packs []Pack) [][]tgbotapi.InlineKeyboardButton {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, pack := range packs {
+		button, err := NewCallbackButton("Subscribe to "+pack.Name, NewCallbackData("subscribe", 1, pack.Name))
+		if err != nil {
+			// pack name is too long to fit in a button, users can still use /subscribe
+			continue
+		}
+
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+	}
+
+	return rows
+}
+
+// browseButton returns a button which shows page of the pack directory sorted by sortBy.
+func browseButton(text, sortBy string, page int) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(text, NewCallbackData("browse", 1, sortBy, strconv.Itoa(page)).String())
+}
+
+// browseMessage returns the text and inline keyboard for a page of the pack directory.
+func browseMessage(packs []Pack, sortBy string, page int, more bool) (string, tgbotapi.InlineKeyboardMarkup) {
+	var text string
+	if sortBy == SortRecent {
+		text = "Here are the newest public gif packs"
+	} else {
+		text = "Here are the most popular public gif packs"
+	}
+	text += fmt.Sprintf(" (page %d):\n", page+1)
+
+	if len(packs) == 0 {
+		text = "Oops, there are no more public gif packs to show."
+	}
+
+	for i, pack := range packs {
+		text += fmt.Sprintf("%d. %s\n", page*browsePageSize+i+1, packSummary(pack))
+	}
+
+	rows := subscribeButtons(packs)
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, browseButton("« Previous", sortBy, page-1))
+	}
+	if more {
+		nav = append(nav, browseButton("Next »", sortBy, page+1))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+
+	if sortBy == SortRecent {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(browseButton("Sort by popularity", SortPopular, 0)))
+	} else {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(browseButton("Sort by newest", SortRecent, 0)))
+	}
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// packButton returns a button which performs action on pack from the /mypacks panel. ok will be false if the pack name
+// is too long to fit in a button.
+func packButton(text, action string, pack Pack) (button tgbotapi.InlineKeyboardButton, ok bool) {
+	button, err := NewCallbackButton(text, NewCallbackData("pack", 1, action, pack.Name))
+	if err != nil {
+		return tgbotapi.InlineKeyboardButton{}, false
+	}
+
+	return button, true
+}
+
+// appendPackButton appends a button which performs action on pack to row, unless the pack name is too long to fit in a
+// button.
+func appendPackButton(row []tgbotapi.InlineKeyboardButton, text, action string, pack Pack) []tgbotapi.InlineKeyboardButton {
+	if button, ok := packButton(text, action, pack); ok {
+		row = append(row, button)
+	}
+
+	return row
+}
+
+// myPacksBackButton returns a button which goes back to the list of a user's packs.
+func myPacksBackButton() tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData("« My packs", NewCallbackData("pack", 1, "list").String())
+}
+
+// myPacksMessage returns the text and inline keyboard listing the packs a user is a member of, with a button to open the
+// panel for each pack.
+func myPacksMessage(userPacks UserPacks) (string, tgbotapi.InlineKeyboardMarkup) {
+	var text string
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	sections := []struct {
+		header string
+		empty  string
+		packs  []Pack
+	}{
+		{"Here are the gif packs you have created:\n", "You have not created any gif packs.\n", userPacks.IsCreator},
+		{"Here are the gif packs you are a contributor to:\n", "You are not a contributor to any gif packs.\n", userPacks.IsContributor},
+		{"Here are the gif packs you can add gifs to:\n", "", userPacks.IsAdder},
+		{"Here are the gif packs you are a viewer of:\n", "", userPacks.IsViewer},
+	}
+
+	for _, section := range sections {
+		if len(section.packs) == 0 {
+			text += section.empty
+			continue
+		}
+
+		text += section.header
+		for i, pack := range section.packs {
+			text += fmt.Sprintf("%d. %s (%s)\n", i+1, pack.Name, packVisibility(pack))
+
+			if button, ok := packButton(pack.Name, "open", pack); ok {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+			}
+		}
+	}
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// packPanel returns the text and inline keyboard for managing pack, with buttons for the things userID can do to it.
+func packPanel(pack Pack, userID int) (string, tgbotapi.InlineKeyboardMarkup) {
+	text := fmt.Sprintf("%s\nVisibility: %s\nYour role: %s\n%d gifs, %d subscribers",
+		pack.Name, packVisibility(pack), PackRole(pack, userID), pack.Gifs, pack.Subscribers)
+	if pack.ForkedFrom != "" {
+		text += fmt.Sprintf("\nForked from %s", pack.ForkedFrom)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	var edit []tgbotapi.InlineKeyboardButton
+	if Can(pack, userID, ActionAddGif) {
+		edit = appendPackButton(edit, "Add gif", "newgif", pack)
+	}
+	if Can(pack, userID, ActionDeleteGif) {
+		edit = appendPackButton(edit, "Delete gif", "deletegif", pack)
+	}
+	if len(edit) > 0 {
+		rows = append(rows, edit)
+	}
+
+	// list gifs by starting an inline query for the pack in the current chat
+	query := pack.Name + " "
+	view := []tgbotapi.InlineKeyboardButton{{Text: "List gifs", SwitchInlineQueryCurrentChat: &query}}
+	view = appendPackButton(view, "Contributors", "members", pack)
+	rows = append(rows, view)
+
+	var manage []tgbotapi.InlineKeyboardButton
+	manage = appendPackButton(manage, "Share", "share", pack)
+	if Can(pack, userID, ActionDeletePack) {
+		manage = appendPackButton(manage, "Delete pack", "delete", pack)
+	}
+	if len(manage) > 0 {
+		rows = append(rows, manage)
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(myPacksBackButton()))
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// packBackButton returns a button which goes back to the panel for pack, or the list of a user's packs if the pack name
+// is too long to fit in a button.
+func packBackButton(pack Pack) tgbotapi.InlineKeyboardButton {
+	if button, ok := packButton("« Back", "open", pack); ok {
+		return button
+	}
+
+	return myPacksBackButton()
+}
+
+// membersText returns a list of the members of pack and their roles.
+func membersText(pack Pack) string {
+	text := fmt.Sprintf("Here are the members of %s:\n", pack.Name)
+	text += fmt.Sprintf("%d (%s)\n", pack.Creator, RoleOwner)
+	for _, members := range []struct {
+		role  Role
+		users []int
+	}{
+		{RoleEditor, pack.Contributors},
+		{RoleAdder, pack.Adders},
+		{RoleViewer, pack.Viewers},
+	} {
+		for _, member := range members.users {
+			text += fmt.Sprintf("%d (%s)\n", member, members.role)
+		}
+	}
+
+	return text
+}
+
+// shareMessage returns the text and inline keyboard for sharing pack with other people.
+func shareMessage(pack Pack) (string, tgbotapi.InlineKeyboardMarkup) {
+	text := fmt.Sprintf("Anyone who opens this link will be subscribed to %s:\n%s", pack.Name, subscribeLink(pack))
+	if pack.Visibility == VisibilityPrivate {
+		text += "\nThis gif pack is private, so only its members can subscribe to it. Use /invite to invite people to it."
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonSwitch("Show it off in a chat", pack.Name+" ")),
+		tgbotapi.NewInlineKeyboardRow(packBackButton(pack)),
+	)
+
+	return text, markup
+}
+
+// confirmDeleteMessage returns the text and inline keyboard asking a user to confirm deleting pack.
+func confirmDeleteMessage(pack Pack) (string, tgbotapi.InlineKeyboardMarkup) {
+	text := fmt.Sprintf("Are you sure you want to delete %s? Its subscribers will no longer be able to use it.", pack.Name)
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if button, ok := packButton("Yes, delete it", "confirmdelete", pack); ok {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(packBackButton(pack)))
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// packNamesKeyboard returns a one-time reply keyboard with a button for each of packNames, so that users waiting to
+// send a pack name can pick one instead of typing it. ok will be false if there are no pack names.
+func packNamesKeyboard(packNames []string) (keyboard tgbotapi.ReplyKeyboardMarkup, ok bool) {
+	if len(packNames) == 0 {
+		return tgbotapi.ReplyKeyboardMarkup{}, false
+	}
+
+	var rows [][]tgbotapi.KeyboardButton
+	for _, packName := range packNames {
+		rows = append(rows, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(packName)))
+	}
+
+	keyboard = tgbotapi.NewReplyKeyboard(rows...)
+	keyboard.OneTimeKeyboard = true
+	keyboard.Selective = true
+
+	return keyboard, true
+}
+
+// setPackNamesKeyboard sets the keyboard of reply, which answers message in a conversation that asked for a pack name.
+// If the conversation is still waiting for a pack name, the names returned by packNames are offered again, since
+// one-time keyboards hide once used. Otherwise the keyboard is removed for the user who was answering. In group chats
+// a conversation which carries on keeps its forced reply instead, since the bot only sees replies to its messages there.
+func setPackNamesKeyboard(reply *tgbotapi.MessageConfig, message *tgbotapi.Message, waiting bool, nextState ConversationState, packNames func() ([]string, error)) error {
+	if waiting {
+		names, err := packNames()
+		if err != nil {
+			return err
+		}
+
+		if keyboard, ok := packNamesKeyboard(names); ok {
+			reply.ReplyMarkup = keyboard
+		}
+
+		return nil
+	}
+
+	if message.Chat.IsPrivate() || nextState.State == stateNone {
+		remove := tgbotapi.NewRemoveKeyboard(true)
+		remove.Selective = true
+		reply.ReplyMarkup = remove
+	}
+
+	return nil
+}
+
+// quickAddKeyboard returns an inline keyboard with a button to add a gif to each of packNames. ok will be false if none
+// of the pack names fit in a button.
+func quickAddKeyboard(packNames []string) (markup tgbotapi.InlineKeyboardMarkup, ok bool) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, packName := range packNames {
+		button, err := NewCallbackButton(packName, NewCallbackData("quickadd", 1, packName))
+		if err != nil {
+			continue
+		}
+
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+	}
+
+	if len(rows) == 0 {
+		return tgbotapi.InlineKeyboardMarkup{}, false
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...), true
+}
+
+// userPackNames returns the names of the packs userID is a member of and can perform action on.
+func userPackNames(ctx context.Context, userID int, action Action) ([]string, error) {
+	userPacks, err := GetUserPacks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var packNames []string
+	for _, packs := range [][]Pack{userPacks.IsCreator, userPacks.IsContributor, userPacks.IsAdder, userPacks.IsViewer} {
+		for _, pack := range packs {
+			if Can(pack, userID, action) {
+				packNames = append(packNames, pack.Name)
+			}
+		}
+	}
+
+	return packNames, nil
+}
+
+// subscribedPackNames returns the names of the packs userID is subscribed to.
+func subscribedPackNames(ctx context.Context, userID int) ([]string, error) {
+	subscriptions, err := MySubscriptions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var packNames []string
+	for _, subscription := range subscriptions {
+		packNames = append(packNames, subscription.Pack)
+	}
+
+	return packNames, nil
+}
+
+// listGifsMessage returns the text and inline keyboard summarising a page of the gifs in pack, with buttons to go to
+// the previous and next pages.
+func listGifsMessage(pack Pack, gifs []Gif, page int, more bool) (string, tgbotapi.InlineKeyboardMarkup) {
+	var text string
+	if len(gifs) == 0 {
+		text = fmt.Sprintf("There are no gifs on this page of %s.", pack.Name)
+	} else {
+		text = fmt.Sprintf("Here are the gifs in %s (page %d):\n", pack.Name, page+1)
+		for i, gif := range gifs {
+			text += fmt.Sprintf("%d. %s\n", page*listGifsPageSize+i+1, gif.Keywords)
+		}
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		if button, err := NewCallbackButton("« Previous", NewCallbackData("listgifs", 1, pack.Name, strconv.Itoa(page-1))); err == nil {
+			row = append(row, button)
+		}
+	}
+	if more {
+		if button, err := NewCallbackButton("Next »", NewCallbackData("listgifs", 1, pack.Name, strconv.Itoa(page+1))); err == nil {
+			row = append(row, button)
+		}
+	}
+
+	if len(row) == 0 {
+		return text, noButtons()
+	}
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(row)
+}
+
+// gifButtons returns an inline keyboard with the buttons for the things userID can do to gif in pack. ok will be false
+// if userID can't do anything to it, or if its id is too long to fit in a button.
+func gifButtons(pack Pack, gif Gif, userID int) (markup tgbotapi.InlineKeyboardMarkup, ok bool) {
+	var row []tgbotapi.InlineKeyboardButton
+	actions := []struct {
+		text   string
+		action string
+		can    Action
+	}{
+		{"Edit", "edit", ActionEditGif},
+		{"Delete", "delete", ActionDeleteGif},
+	}
+
+	for _, a := range actions {
+		if !Can(pack, userID, a.can) {
+			continue
+		}
+
+		button, err := NewCallbackButton(a.text, NewCallbackData("gif", 1, a.action, pack.Name, gifID(gif)))
+		if err != nil {
+			return tgbotapi.InlineKeyboardMarkup{}, false
+		}
+
+		row = append(row, button)
+	}
+
+	if len(row) == 0 {
+		return tgbotapi.InlineKeyboardMarkup{}, false
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(row), true
+}
+
+// confirmDeleteGifButtons returns an inline keyboard asking for confirmation before deleting gif from pack.
+func confirmDeleteGifButtons(pack Pack, gif Gif) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	if button, err := NewCallbackButton("Yes, delete it", NewCallbackData("gif", 1, "confirmdelete", pack.Name, gifID(gif))); err == nil {
+		row = append(row, button)
+	}
+	if button, err := NewCallbackButton("Keep it", NewCallbackData("gif", 1, "keep", pack.Name, gifID(gif))); err == nil {
+		row = append(row, button)
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(row)
+}
+
+// noButtons returns an inline keyboard without any buttons, for removing the buttons from a message.
+func noButtons() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/appengine/search"
+)
+
+func TestPackNamesKeyboard(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no packs", func(t *testing.T) {
+		_, ok := packNamesKeyboard(nil)
+		assert.Equal(t, ok, false)
+	})
+
+	t.Run("packs", func(t *testing.T) {
+		keyboard, ok := packNamesKeyboard([]string{"FOO", "BAR"})
+		assert.Equal(t, ok, true)
+		assert.Equal(t, len(keyboard.Keyboard), 2)
+		assert.Equal(t, keyboard.Keyboard[0][0].Text, "FOO")
+		assert.Equal(t, keyboard.Keyboard[1][0].Text, "BAR")
+		assert.Equal(t, keyboard.OneTimeKeyboard, true)
+		assert.Equal(t, keyboard.Selective, true)
+	})
+}
+
+func TestSetPackNamesKeyboard(t *testing.T) {
+	t.Parallel()
+
+	private := &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{Type: "private"}}
+	group := &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{Type: "group"}}
+	forceReply := tgbotapi.ForceReply{ForceReply: true, Selective: true}
+	packNames := func() ([]string, error) {
+		return []string{"FOO"}, nil
+	}
+
+	t.Run("waiting", func(t *testing.T) {
+		reply := tgbotapi.MessageConfig{}
+		err := setPackNamesKeyboard(&reply, group, true, ConversationState{State: stateNewGifWaitPackName}, packNames)
+		assert.Equal(t, err, nil)
+
+		keyboard, ok := reply.ReplyMarkup.(tgbotapi.ReplyKeyboardMarkup)
+		assert.True(t, ok)
+		assert.Equal(t, keyboard.Keyboard[0][0].Text, "FOO")
+	})
+
+	t.Run("private", func(t *testing.T) {
+		reply := tgbotapi.MessageConfig{}
+		err := setPackNamesKeyboard(&reply, private, false, ConversationState{State: stateNewGifWaitGif}, packNames)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, reply.ReplyMarkup, tgbotapi.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: true})
+	})
+
+	t.Run("group finished", func(t *testing.T) {
+		reply := tgbotapi.MessageConfig{}
+		reply.ReplyMarkup = forceReply
+		err := setPackNamesKeyboard(&reply, group, false, ConversationState{}, packNames)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, reply.ReplyMarkup, tgbotapi.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: true})
+	})
+
+	t.Run("group carries on", func(t *testing.T) {
+		reply := tgbotapi.MessageConfig{}
+		reply.ReplyMarkup = forceReply
+		err := setPackNamesKeyboard(&reply, group, false, ConversationState{State: stateNewGifWaitGif}, packNames)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, reply.ReplyMarkup, forceReply)
+	})
+}
+
+func TestQuickAddKeyboard(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no packs", func(t *testing.T) {
+		_, ok := quickAddKeyboard(nil)
+		assert.Equal(t, ok, false)
+	})
+
+	t.Run("packs", func(t *testing.T) {
+		markup, ok := quickAddKeyboard([]string{"FOO", strings.Repeat("A", maxCallbackDataLength)})
+		assert.Equal(t, ok, true)
+		assert.Equal(t, len(markup.InlineKeyboard), 1)
+		assert.Equal(t, *markup.InlineKeyboard[0][0].CallbackData, "quickadd:1:FOO")
+	})
+}
+
+func TestListGifsMessage(t *testing.T) {
+	t.Parallel()
+
+	pack := Pack{Name: "PACK"}
+	gifs := []Gif{{Keywords: "cat"}, {Keywords: "dog"}}
+
+	t.Run("first page", func(t *testing.T) {
+		text, markup := listGifsMessage(pack, gifs, 0, true)
+		assert.Equal(t, text, "Here are the gifs in PACK (page 1):\n1. cat\n2. dog\n")
+		assert.Equal(t, len(markup.InlineKeyboard), 1)
+		assert.Equal(t, len(markup.InlineKeyboard[0]), 1)
+		assert.Equal(t, *markup.InlineKeyboard[0][0].CallbackData, "listgifs:1:PACK:1")
+	})
+
+	t.Run("middle page", func(t *testing.T) {
+		text, markup := listGifsMessage(pack, gifs, 1, true)
+		assert.Equal(t, text, fmt.Sprintf("Here are the gifs in PACK (page 2):\n%d. cat\n%d. dog\n", listGifsPageSize+1, listGifsPageSize+2))
+		assert.Equal(t, len(markup.InlineKeyboard[0]), 2)
+		assert.Equal(t, *markup.InlineKeyboard[0][0].CallbackData, "listgifs:1:PACK:0")
+		assert.Equal(t, *markup.InlineKeyboard[0][1].CallbackData, "listgifs:1:PACK:2")
+	})
+
+	t.Run("only page", func(t *testing.T) {
+		_, markup := listGifsMessage(pack, gifs, 0, false)
+		assert.Equal(t, len(markup.InlineKeyboard), 0)
+	})
+}
+
+func TestGifButtons(t *testing.T) {
+	t.Parallel()
+
+	pack := Pack{Name: "PACK", Creator: 1, Adders: []int{2}}
+	gif := Gif{FileID: "file", FileUniqueID: "unique"}
+
+	t.Run("owner", func(t *testing.T) {
+		markup, ok := gifButtons(pack, gif, 1)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, len(markup.InlineKeyboard[0]), 2)
+		assert.Equal(t, *markup.InlineKeyboard[0][0].CallbackData, "gif:1:edit:PACK:unique")
+		assert.Equal(t, *markup.InlineKeyboard[0][1].CallbackData, "gif:1:delete:PACK:unique")
+	})
+
+	t.Run("not an editor", func(t *testing.T) {
+		_, ok := gifButtons(pack, gif, 2)
+		assert.Equal(t, ok, false)
+	})
+
+	t.Run("id too long", func(t *testing.T) {
+		_, ok := gifButtons(pack, Gif{FileID: search.Atom(strings.Repeat("A", maxCallbackDataLength))}, 1)
+		assert.Equal(t, ok, false)
+	})
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/search"
+)
+
+const (
+	// maxListedKeywords is the maximum number of keywords shown by /keywords
+	maxListedKeywords = 100
+
+	// maxKeywordArticles is the maximum number of keywords offered as inline results, which is the most Telegram allows
+	maxKeywordArticles = 50
+)
+
+// stopWords are common English words which are left out of search queries with other words because they match too
+// many gifs
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true, "by": true,
+	"for": true, "if": true, "in": true, "into": true, "is": true, "it": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "such": true, "that": true, "the": true, "their": true, "then": true, "there": true,
+	"these": true, "they": true, "this": true, "to": true, "was": true, "will": true, "with": true,
+}
+
+// KeywordOptions controls the optional steps of keyword normalisation. Gifs must be reindexed with /admin/reindex
+// after changing them so that their stored terms match new queries.
+type KeywordOptions struct {
+	// Stem reduces English words to their stems, so that "dancing" matches "dance"
+	Stem bool
+	// StopWords leaves common English words like "the" out of queries which have other words
+	StopWords bool
+	// EmojiAliases adds the words an emoji stands for, so that 😂 matches "laugh"
+	EmojiAliases bool
+}
+
+// keywordOptions returns the keyword normalisation options, which are turned on unless the KEYWORD_STEMMING,
+// KEYWORD_STOP_WORDS or KEYWORD_EMOJI_ALIASES environment variables are set to false.
+func keywordOptions() KeywordOptions {
+	return KeywordOptions{
+		Stem:         os.Getenv("KEYWORD_STEMMING") != "false",
+		StopWords:    os.Getenv("KEYWORD_STOP_WORDS") != "false",
+		EmojiAliases: os.Getenv("KEYWORD_EMOJI_ALIASES") != "false",
+	}
+}
+
+// foldCase maps r to a single case so that keywords match regardless of case, including letters like the Greek final
+// sigma and the Kelvin sign whose lowercase forms differ from what strings.ToLower gives.
+func foldCase(r rune) rune {
+	return unicode.ToLower(unicode.ToUpper(r))
+}
+
+// tokenise splits text into case folded words, treating punctuation as a separator. Apostrophes are dropped instead so
+// that "don't" stays one word. Each emoji is a word of its own. Chinese, Japanese and Korean text, which is often
+// written without spaces, is split into every character and every pair of neighbouring characters, so that part of a
+// phrase still matches it.
+func tokenise(text string) []string {
+	var tokens []string
+	var token, cjk []rune
+	flush := func() {
+		if len(token) > 0 {
+			tokens = append(tokens, string(token))
+			token = token[:0]
+		}
+
+		tokens = append(tokens, cjkNgrams(cjk)...)
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case r == '\'' || r == '’' || isEmojiModifier(r):
+			continue
+		case unicode.IsSpace(r) || unicode.IsPunct(r):
+			flush()
+		case isEmoji(r):
+			flush()
+			tokens = append(tokens, string(r))
+		case isCJK(r):
+			if len(token) > 0 {
+				flush()
+			}
+			cjk = append(cjk, r)
+		default:
+			if len(cjk) > 0 {
+				flush()
+			}
+			token = append(token, foldCase(r))
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// isCJK returns true if r is a Chinese, Japanese or Korean character.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// cjkNgrams returns every character in run followed by every pair of neighbouring characters.
+func cjkNgrams(run []rune) []string {
+	var ngrams []string
+	for _, r := range run {
+		ngrams = append(ngrams, string(r))
+	}
+
+	for i := 0; i+1 < len(run); i++ {
+		ngrams = append(ngrams, string(run[i:i+2]))
+	}
+
+	return ngrams
+}
+
+// normaliseKeywords returns the distinct search terms for keywords. The same normalisation must be used when indexing
+// gifs and when searching them, apart from stop words. Stop words are only left out if there are other words, so that
+// searching for just "the" still finds something.
+func normaliseKeywords(keywords string, opts KeywordOptions) []string {
+	tokens := tokenise(keywords)
+
+	if opts.StopWords {
+		var kept []string
+		for _, token := range tokens {
+			if !stopWords[token] {
+				kept = append(kept, token)
+			}
+		}
+
+		if len(kept) > 0 {
+			tokens = kept
+		}
+	}
+
+	if opts.EmojiAliases {
+		var aliases []string
+		for _, token := range tokens {
+			aliases = append(aliases, emojiAliases[token]...)
+		}
+		tokens = append(tokens, aliases...)
+	}
+
+	var terms []string
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		if opts.Stem {
+			token = stem(token)
+		}
+
+		if !seen[token] {
+			seen[token] = true
+			terms = append(terms, token)
+		}
+	}
+
+	return terms
+}
+
+// keywordTerms returns the normalised search terms for keywords as stored in Gif.Terms. Stop words are kept in stored
+// terms and only left out of queries, so that searching for just "no" still finds gifs tagged "oh no".
+func keywordTerms(keywords string) string {
+	opts := keywordOptions()
+	opts.StopWords = false
+	return strings.Join(normaliseKeywords(keywords, opts), " ")
+}
+
+// quoteTerms returns terms quoted for use in a search query, so that emoji and other symbols are not read as query
+// syntax. Terms never contain quotes since tokenise treats them as punctuation.
+func quoteTerms(terms []string) []string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = strconv.Quote(term)
+	}
+
+	return quoted
+}
+
+// KeywordCount is a keyword used in a pack along with the number of gifs tagged with it.
+type KeywordCount struct {
+	Keyword string
+	Gifs    int
+}
+
+// PackKeywords returns every keyword used in packName, most used first. err will be ErrNotFound if userID cannot see
+// packName.
+func PackKeywords(ctx context.Context, packName string, userID int) ([]KeywordCount, error) {
+	// validate pack name
+	if !packNameRegex.MatchString(packName) {
+		return nil, ErrInvalidName
+	}
+
+	// normalise pack name
+	packName = strings.ToUpper(packName)
+
+	pack, err := GetPack(ctx, packName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !Can(pack, userID, ActionView) {
+		return nil, ErrNotFound
+	}
+
+	index, err := search.Open(gifsIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	var gifs []Gif
+	for t := index.Search(ctx, "Pack = "+packName, nil); ; {
+		var gif Gif
+		_, err := t.Next(&gif)
+		if err == search.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		gifs = append(gifs, gif)
+	}
+
+	return countKeywords(gifs), nil
+}
+
+// countKeywords returns the number of gifs tagged with each keyword, split and case folded by tokenise so that case and
+// punctuation are ignored, sorted by the number of gifs and then alphabetically. A keyword repeated on one gif is only
+// counted once.
+func countKeywords(gifs []Gif) []KeywordCount {
+	counts := make(map[string]int)
+	for _, gif := range gifs {
+		seen := make(map[string]bool)
+		for _, keyword := range tokenise(gif.Keywords) {
+			if !seen[keyword] {
+				seen[keyword] = true
+				counts[keyword]++
+			}
+		}
+	}
+
+	keywords := make([]KeywordCount, 0, len(counts))
+	for keyword, count := range counts {
+		keywords = append(keywords, KeywordCount{Keyword: keyword, Gifs: count})
+	}
+
+	sort.Slice(keywords, func(i, j int) bool {
+		if keywords[i].Gifs != keywords[j].Gifs {
+			return keywords[i].Gifs > keywords[j].Gifs
+		}
+
+		return keywords[i].Keyword < keywords[j].Keyword
+	})
+
+	return keywords
+}
+
+// keywordsMessage returns the text listing the keywords used in packName, most used first.
+func keywordsMessage(packName string, keywords []KeywordCount) string {
+	if len(keywords) == 0 {
+		return fmt.Sprintf("Oops, there aren't any keywords in %s yet.", packName)
+	}
+
+	text := fmt.Sprintf("Here are the keywords used in %s:\n", packName)
+	for i, k := range keywords {
+		if i == maxListedKeywords {
+			text += fmt.Sprintf("...and %s more", pluralise(len(keywords)-i, "keyword", "keywords"))
+			break
+		}
+
+		text += fmt.Sprintf("%s (%s)\n", k.Keyword, pluralise(k.Gifs, "gif", "gifs"))
+	}
+
+	return text
+}
+
+// parseKeywordsQuery returns the pack name from an inline query like "pack_name ?" or "pack_name?", which asks for the
+// keywords used in a pack. ok will be false if query is not in that form.
+func parseKeywordsQuery(query string) (packName string, ok bool) {
+	fields := strings.Fields(query)
+	switch {
+	case len(fields) == 2 && fields[1] == "?":
+		packName = fields[0]
+	case len(fields) == 1 && strings.HasSuffix(fields[0], "?"):
+		packName = strings.TrimSuffix(fields[0], "?")
+	default:
+		return "", false
+	}
+
+	if packName == "" || packName == "-" {
+		return "", false
+	}
+
+	return packName, true
+}
+
+// keywordArticles returns inline results for the keywords used in packName. Choosing one sends the keyword, and its
+// button searches packName for it.
+func keywordArticles(packName string, keywords []KeywordCount) []interface{} {
+	results := make([]interface{}, 0)
+	for i, k := range keywords {
+		if i == maxKeywordArticles {
+			break
+		}
+
+		article := tgbotapi.NewInlineQueryResultArticle(strconv.Itoa(i), k.Keyword, k.Keyword)
+		article.Description = fmt.Sprintf("Used by %s", pluralise(k.Gifs, "gif", "gifs"))
+
+		query := fmt.Sprintf("%s %s", packName, k.Keyword)
+		markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(tgbotapi.InlineKeyboardButton{
+			Text:                         "Show gifs",
+			SwitchInlineQueryCurrentChat: &query,
+		}))
+		article.ReplyMarkup = &markup
+
+		results = append(results, article)
+	}
+
+	return results
+}
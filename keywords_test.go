@@ -0,0 +1,150 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountKeywords(t *testing.T) {
+	t.Parallel()
+
+	gifs := []Gif{
+		{Keywords: "cat funny"},
+		{Keywords: "Cat cat dog"},
+		{Keywords: "dog"},
+		{Keywords: "bird"},
+		{Keywords: "cat, bird!"},
+		{Keywords: "CAT/dog"},
+	}
+
+	expected := []KeywordCount{
+		{"cat", 4},
+		{"dog", 3},
+		{"bird", 2},
+		{"funny", 1},
+	}
+	assert.Equal(t, countKeywords(gifs), expected)
+}
+
+func TestKeywordsMessage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no keywords", func(t *testing.T) {
+		assert.Equal(t, keywordsMessage("PACK", nil), "Oops, there aren't any keywords in PACK yet.")
+	})
+
+	t.Run("keywords", func(t *testing.T) {
+		text := keywordsMessage("PACK", []KeywordCount{{"cat", 2}, {"dog", 1}})
+		assert.Equal(t, text, "Here are the keywords used in PACK:\ncat (2 gifs)\ndog (1 gif)\n")
+	})
+}
+
+func TestParseKeywordsQuery(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		query    string
+		packName string
+		ok       bool
+	}{
+		{"pack ?", "pack", true},
+		{"  pack   ? ", "pack", true},
+		{"pack?", "pack", true},
+		{" pack? ", "pack", true},
+		{"?", "", false},
+		{"-?", "", false},
+		{"pack", "", false},
+		{"pack cat", "", false},
+		{"pack ? cat", "", false},
+		{"- ?", "", false},
+	}
+
+	for _, tc := range testCases {
+		packName, ok := parseKeywordsQuery(tc.query)
+		assert.Equal(t, packName, tc.packName, tc.query)
+		assert.Equal(t, ok, tc.ok, tc.query)
+	}
+}
+
+func TestKeywordArticles(t *testing.T) {
+	t.Parallel()
+
+	results := keywordArticles("pack", []KeywordCount{{"cat", 2}})
+	assert.Equal(t, len(results), 1)
+
+	article := results[0].(tgbotapi.InlineQueryResultArticle)
+	assert.Equal(t, article.Title, "cat")
+	assert.Equal(t, article.Description, "Used by 2 gifs")
+	assert.Equal(t, *article.ReplyMarkup.InlineKeyboard[0][0].SwitchInlineQueryCurrentChat, "pack cat")
+}
+
+func TestTokenise(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		text     string
+		expected []string
+	}{
+		{"", nil},
+		{"  Funny   CAT ", []string{"funny", "cat"}},
+		{"don't stop!", []string{"dont", "stop"}},
+		{"cat,dog/bird-fish", []string{"cat", "dog", "bird", "fish"}},
+		{"ΟΔΟΣ", []string{"οδοσ"}},
+		{"Straße", []string{"straße"}},
+		{"可爱猫咪", []string{"可", "爱", "猫", "咪", "可爱", "爱猫", "猫咪"}},
+		{"猫", []string{"猫"}},
+		{"cute猫 dog", []string{"cute", "猫", "dog"}},
+		{"😂😂 lol", []string{"😂", "😂", "lol"}},
+		{"cat😂", []string{"cat", "😂"}},
+		{"👍🏽 ❤️", []string{"👍", "❤"}},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tokenise(tc.text), tc.expected, tc.text)
+	}
+}
+
+func TestNormaliseKeywords(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		keywords string
+		opts     KeywordOptions
+		expected []string
+	}{
+		{"The Dancing Cats", KeywordOptions{}, []string{"the", "dancing", "cats"}},
+		{"The Dancing Cats", KeywordOptions{StopWords: true}, []string{"dancing", "cats"}},
+		{"The Dancing Cats", KeywordOptions{Stem: true, StopWords: true}, []string{"danc", "cat"}},
+		{"dance dancing DANCED", KeywordOptions{Stem: true}, []string{"danc"}},
+		{"the", KeywordOptions{StopWords: true}, []string{"the"}},
+		{"😂", KeywordOptions{}, []string{"😂"}},
+		{"😂 laughing", KeywordOptions{Stem: true, EmojiAliases: true}, []string{"😂", "laugh", "lol"}},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, normaliseKeywords(tc.keywords, tc.opts), tc.expected, tc.keywords)
+	}
+}
+
+func TestKeywordTerms(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stop words are kept", func(t *testing.T) {
+		assert.Equal(t, keywordTerms("The Office"), "the offic")
+	})
+
+	t.Run("single stop word query", func(t *testing.T) {
+		query := normaliseKeywords("no", keywordOptions())
+		assert.Equal(t, query, []string{"no"})
+		assert.Contains(t, strings.Fields(keywordTerms("oh no")), query[0])
+	})
+}
+
+func TestQuoteTerms(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, quoteTerms([]string{"cat", "😂", "猫咪"}), []string{`"cat"`, `"😂"`, `"猫咪"`})
+}
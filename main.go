@@ -32,6 +32,107 @@ func NewInlineQueryResultCachedMpeg4Gif(id, fileID string) InlineQueryResultCach
 	}
 }
 
+type InlineQueryResultCachedGif struct {
+	Type                string                         `json:"type"`        // required
+	ID                  string                         `json:"id"`          // required
+	GifFileID           string                         `json:"gif_file_id"` // required
+	Title               string                         `json:"title"`
+	Caption             string                         `json:"caption"`
+	ReplyMarkup         *tgbotapi.InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+	InputMessageContent interface{}                    `json:"input_message_content,omitempty"`
+}
+
+func NewInlineQueryResultCachedGif(id, fileID string) InlineQueryResultCachedGif {
+	return InlineQueryResultCachedGif{
+		Type:      "gif",
+		ID:        id,
+		GifFileID: fileID,
+	}
+}
+
+type InlineQueryResultCachedSticker struct {
+	Type                string                         `json:"type"`            // required
+	ID                  string                         `json:"id"`              // required
+	StickerFileID       string                         `json:"sticker_file_id"` // required
+	ReplyMarkup         *tgbotapi.InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+	InputMessageContent interface{}                    `json:"input_message_content,omitempty"`
+}
+
+func NewInlineQueryResultCachedSticker(id, fileID string) InlineQueryResultCachedSticker {
+	return InlineQueryResultCachedSticker{
+		Type:          "sticker",
+		ID:            id,
+		StickerFileID: fileID,
+	}
+}
+
+type InlineQueryResultCachedPhoto struct {
+	Type                string                         `json:"type"`          // required
+	ID                  string                         `json:"id"`            // required
+	PhotoFileID         string                         `json:"photo_file_id"` // required
+	Title               string                         `json:"title"`
+	Description         string                         `json:"description"`
+	Caption             string                         `json:"caption"`
+	ReplyMarkup         *tgbotapi.InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+	InputMessageContent interface{}                    `json:"input_message_content,omitempty"`
+}
+
+func NewInlineQueryResultCachedPhoto(id, fileID string) InlineQueryResultCachedPhoto {
+	return InlineQueryResultCachedPhoto{
+		Type:        "photo",
+		ID:          id,
+		PhotoFileID: fileID,
+	}
+}
+
+type InlineQueryResultCachedVideo struct {
+	Type                string                         `json:"type"`          // required
+	ID                  string                         `json:"id"`            // required
+	VideoFileID         string                         `json:"video_file_id"` // required
+	Title               string                         `json:"title"`         // required
+	Description         string                         `json:"description"`
+	Caption             string                         `json:"caption"`
+	ReplyMarkup         *tgbotapi.InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+	InputMessageContent interface{}                    `json:"input_message_content,omitempty"`
+}
+
+func NewInlineQueryResultCachedVideo(id, fileID, title string) InlineQueryResultCachedVideo {
+	return InlineQueryResultCachedVideo{
+		Type:        "video",
+		ID:          id,
+		VideoFileID: fileID,
+		Title:       title,
+	}
+}
+
+type InlineQueryResultCachedVoice struct {
+	Type                string                         `json:"type"`          // required
+	ID                  string                         `json:"id"`            // required
+	VoiceFileID         string                         `json:"voice_file_id"` // required
+	Title               string                         `json:"title"`         // required
+	Caption             string                         `json:"caption"`
+	ReplyMarkup         *tgbotapi.InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+	InputMessageContent interface{}                    `json:"input_message_content,omitempty"`
+}
+
+func NewInlineQueryResultCachedVoice(id, fileID, title string) InlineQueryResultCachedVoice {
+	return InlineQueryResultCachedVoice{
+		Type:        "voice",
+		ID:          id,
+		VoiceFileID: fileID,
+		Title:       title,
+	}
+}
+
+// botUsername returns the username of the bot, which is used to build deep links.
+func botUsername() string {
+	if username := os.Getenv("TELEGRAM_BOT_USERNAME"); username != "" {
+		return username
+	}
+
+	return "SavedGIFsBot"
+}
+
 func rootHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Hello, World"))
 }
@@ -59,34 +160,34 @@ func webhookHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client := urlfetch.Client(ctx)
-	bot := tgbotapi.BotAPI{
-		Token:  os.Getenv("TELEGRAM_BOT_TOKEN"),
-		Client: client,
-	}
+	ctx = withFileUniqueIDs(ctx, bytes)
+
+	bot := newBot(ctx)
 
 	if message := update.Message; message != nil {
 		// handle a new command
 		if command := message.Command(); command != "" {
 			if handler, exists := commandHandlers[command]; exists {
 				// clear bot state before a new command
-				err := ClearConversationState(ctx, message.Chat.ID, message.From.ID)
-				if err != nil {
-					SomethingWentWrong(ctx, &bot, message, err)
-					return
+				if !statefulCommands[command] {
+					err := ClearConversationState(ctx, message.Chat.ID, message.From.ID)
+					if err != nil {
+						SomethingWentWrong(ctx, bot, message, err)
+						return
+					}
 				}
 
-				err = handler(ctx, &bot, message)
+				err := handler(ctx, bot, message)
 				if err != nil {
-					SomethingWentWrong(ctx, &bot, message, err)
+					SomethingWentWrong(ctx, bot, message, err)
 					return
 				}
 			}
 		} else {
 			// or continue based on the previous conversation state
-			err := Transduce(ctx, &bot, message)
+			err := Transduce(ctx, bot, message)
 			if err != nil {
-				SomethingWentWrong(ctx, &bot, message, err)
+				SomethingWentWrong(ctx, bot, message, err)
 				return
 			}
 		}
@@ -95,11 +196,24 @@ func webhookHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if inlineQuery := update.InlineQuery; inlineQuery != nil {
-		HandleInlineQuery(ctx, &bot, inlineQuery)
+		HandleInlineQuery(ctx, bot, inlineQuery)
+		return
+	}
+
+	if callbackQuery := update.CallbackQuery; callbackQuery != nil {
+		HandleCallbackQuery(ctx, bot, callbackQuery)
 		return
 	}
 }
 
+// newBot returns a telegram bot api client which makes requests through urlfetch.
+func newBot(ctx context.Context) *tgbotapi.BotAPI {
+	return &tgbotapi.BotAPI{
+		Token:  os.Getenv("TELEGRAM_BOT_TOKEN"),
+		Client: urlfetch.Client(ctx),
+	}
+}
+
 // SomethingWentWrong replies to a message saying that something went wrong and provides a request id for reporting the
 // error.
 func SomethingWentWrong(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, err error) {
@@ -115,6 +229,9 @@ func SomethingWentWrong(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbo
 
 func init() {
 	http.HandleFunc("/", rootHandler)
+	http.HandleFunc("/admin/recount", recountHandler)
+	http.HandleFunc("/admin/rekey", rekeyHandler)
+	http.HandleFunc("/admin/reindex", reindexHandler)
 
 	if token := os.Getenv("TELEGRAM_BOT_TOKEN"); token != "" {
 		http.HandleFunc("/"+token, webhookHandler)
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"golang.org/x/net/context"
+)
+
+// media types, named after the cached inline query results used to send them
+const (
+	MediaTypeMpeg4Gif = "mpeg4_gif"
+	MediaTypeGif      = "gif"
+	MediaTypeSticker  = "sticker"
+	MediaTypePhoto    = "photo"
+	MediaTypeVideo    = "video"
+	MediaTypeVoice    = "voice"
+)
+
+// gifMediaTypes maps the mime types of gifs to their media types
+var gifMediaTypes = map[string]string{
+	"video/mp4": MediaTypeMpeg4Gif,
+	"image/gif": MediaTypeGif,
+}
+
+// gifMedia returns the file id and media type of the gif in message. Gifs can arrive as animations or as documents which
+// are either mp4 videos or real gifs. Silent mp4 videos sent as animations arrive as an animation along with a
+// video/mp4 document, so they are covered too. Videos sent as plain videos are not gifs, since they may have sound and
+// the Bot API does not say whether they do. ok will be false if message does not contain a gif.
+func gifMedia(message *tgbotapi.Message) (fileID, mediaType string, ok bool) {
+	if message == nil {
+		return "", "", false
+	}
+
+	// animations also come with a document for older clients, so check them first
+	if animation := message.Animation; animation != nil {
+		mediaType, ok := gifMediaTypes[animation.MimeType]
+		if !ok {
+			// telegram converts most animations to mp4 and does not always say so
+			mediaType = MediaTypeMpeg4Gif
+		}
+
+		return animation.FileID, mediaType, true
+	}
+
+	if document := message.Document; document != nil {
+		if mediaType, ok := gifMediaTypes[document.MimeType]; ok {
+			return document.FileID, mediaType, true
+		}
+	}
+
+	return "", "", false
+}
+
+// messageMedia returns the file id and media type of the gif, sticker, photo, video or voice note in message. ok will
+// be false if message does not contain any media which can be saved to a pack.
+func messageMedia(message *tgbotapi.Message) (fileID, mediaType string, ok bool) {
+	if fileID, mediaType, ok := gifMedia(message); ok {
+		return fileID, mediaType, true
+	}
+
+	if message == nil {
+		return "", "", false
+	}
+
+	switch {
+	case message.Sticker != nil:
+		return message.Sticker.FileID, MediaTypeSticker, true
+	case message.Photo != nil && len(*message.Photo) > 0:
+		// photos come in several sizes, with the largest last
+		photos := *message.Photo
+		return photos[len(photos)-1].FileID, MediaTypePhoto, true
+	case message.Video != nil:
+		return message.Video.FileID, MediaTypeVideo, true
+	case message.Voice != nil:
+		return message.Voice.FileID, MediaTypeVoice, true
+	}
+
+	return "", "", false
+}
+
+// gifMediaType returns the media type of gif, which is mpeg4_gif for gifs saved before media types were recorded.
+func gifMediaType(gif Gif) string {
+	if gif.Type == "" {
+		return MediaTypeMpeg4Gif
+	}
+
+	return string(gif.Type)
+}
+
+// fileUniqueIDsKey is the context key for the file unique ids in the current update
+type fileUniqueIDsKey struct{}
+
+// withFileUniqueIDs returns a copy of ctx carrying the file unique ids of every file in update, which is the raw JSON of
+// an update from telegram. The telegram bot api library does not know about file unique ids, so they are read from the
+// raw update instead.
+func withFileUniqueIDs(ctx context.Context, update []byte) context.Context {
+	var v interface{}
+	err := json.Unmarshal(update, &v)
+	if err != nil {
+		return ctx
+	}
+
+	ids := make(map[string]string)
+	collectFileUniqueIDs(v, ids)
+
+	return context.WithValue(ctx, fileUniqueIDsKey{}, ids)
+}
+
+// collectFileUniqueIDs walks a decoded JSON value, adding the file unique id of every object which has both a file id
+// and a file unique id to ids.
+func collectFileUniqueIDs(v interface{}, ids map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		fileID, _ := v["file_id"].(string)
+		fileUniqueID, _ := v["file_unique_id"].(string)
+		if fileID != "" && fileUniqueID != "" {
+			ids[fileID] = fileUniqueID
+		}
+
+		for _, child := range v {
+			collectFileUniqueIDs(child, ids)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectFileUniqueIDs(child, ids)
+		}
+	}
+}
+
+// fileUniqueID returns the file unique id of the file with fileID in the current update, or an empty string if it is
+// not known.
+func fileUniqueID(ctx context.Context, fileID string) string {
+	ids, _ := ctx.Value(fileUniqueIDsKey{}).(map[string]string)
+	return ids[fileID]
+}
+
+// mediaID returns the id which gifs are stored under for the file with fileID in the current update. This is its file
+// unique id, which stays the same however the file is sent, or fileID if the file unique id is not known.
+func mediaID(ctx context.Context, fileID string) string {
+	if fileUniqueID := fileUniqueID(ctx, fileID); fileUniqueID != "" {
+		return fileUniqueID
+	}
+
+	return fileID
+}
+
+// mediaShare returns a message sending gif to chatID with caption and markup, using the right method for its media
+// type. Stickers cannot have captions, so caption is left out for them.
+func mediaShare(chatID int64, gif Gif, caption string, markup interface{}) tgbotapi.Chattable {
+	fileID := string(gif.FileID)
+	switch gifMediaType(gif) {
+	case MediaTypeSticker:
+		config := tgbotapi.NewStickerShare(chatID, fileID)
+		config.ReplyMarkup = markup
+		return config
+	case MediaTypePhoto:
+		config := tgbotapi.NewPhotoShare(chatID, fileID)
+		config.Caption = caption
+		config.ReplyMarkup = markup
+		return config
+	case MediaTypeVideo:
+		config := tgbotapi.NewVideoShare(chatID, fileID)
+		config.Caption = caption
+		config.ReplyMarkup = markup
+		return config
+	case MediaTypeVoice:
+		config := tgbotapi.NewVoiceShare(chatID, fileID)
+		config.Caption = caption
+		config.ReplyMarkup = markup
+		return config
+	default:
+		config := tgbotapi.NewAnimationShare(chatID, fileID)
+		config.Caption = caption
+		config.ReplyMarkup = markup
+		return config
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/search"
+)
+
+func TestGifMedia(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		message   *tgbotapi.Message
+		fileID    string
+		mediaType string
+		ok        bool
+	}{
+		{"nil message", nil, "", "", false},
+		{"text", &tgbotapi.Message{Text: "hello"}, "", "", false},
+		{"mp4 document", &tgbotapi.Message{Document: &tgbotapi.Document{FileID: "a", MimeType: "video/mp4"}}, "a", MediaTypeMpeg4Gif, true},
+		{"gif document", &tgbotapi.Message{Document: &tgbotapi.Document{FileID: "b", MimeType: "image/gif"}}, "b", MediaTypeGif, true},
+		{"other document", &tgbotapi.Message{Document: &tgbotapi.Document{FileID: "c", MimeType: "application/pdf"}}, "", "", false},
+		{"animation", &tgbotapi.Message{
+			Animation: &tgbotapi.ChatAnimation{FileID: "d"},
+			Document:  &tgbotapi.Document{FileID: "e", MimeType: "video/mp4"},
+		}, "d", MediaTypeMpeg4Gif, true},
+		{"video", &tgbotapi.Message{Video: &tgbotapi.Video{FileID: "f", MimeType: "video/mp4"}}, "", "", false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fileID, mediaType, ok := gifMedia(test.message)
+			assert.Equal(t, fileID, test.fileID)
+			assert.Equal(t, mediaType, test.mediaType)
+			assert.Equal(t, ok, test.ok)
+		})
+	}
+}
+
+func TestMessageMedia(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		message   *tgbotapi.Message
+		fileID    string
+		mediaType string
+		ok        bool
+	}{
+		{"nil message", nil, "", "", false},
+		{"text", &tgbotapi.Message{Text: "hello"}, "", "", false},
+		{"gif", &tgbotapi.Message{Document: &tgbotapi.Document{FileID: "a", MimeType: "video/mp4"}}, "a", MediaTypeMpeg4Gif, true},
+		{"sticker", &tgbotapi.Message{Sticker: &tgbotapi.Sticker{FileID: "b"}}, "b", MediaTypeSticker, true},
+		{"photo", &tgbotapi.Message{Photo: &[]tgbotapi.PhotoSize{{FileID: "small"}, {FileID: "large"}}}, "large", MediaTypePhoto, true},
+		{"no photo sizes", &tgbotapi.Message{Photo: &[]tgbotapi.PhotoSize{}}, "", "", false},
+		{"video", &tgbotapi.Message{Video: &tgbotapi.Video{FileID: "c"}}, "c", MediaTypeVideo, true},
+		{"voice", &tgbotapi.Message{Voice: &tgbotapi.Voice{FileID: "d"}}, "d", MediaTypeVoice, true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fileID, mediaType, ok := messageMedia(test.message)
+			assert.Equal(t, fileID, test.fileID)
+			assert.Equal(t, mediaType, test.mediaType)
+			assert.Equal(t, ok, test.ok)
+		})
+	}
+}
+
+func TestInlineQueryResult(t *testing.T) {
+	t.Parallel()
+
+	t.Run("mpeg4 gif", func(t *testing.T) {
+		result := inlineQueryResult("a", Gif{FileID: "a"})
+		assert.Equal(t, result, NewInlineQueryResultCachedMpeg4Gif("a", "a"))
+	})
+
+	t.Run("gif", func(t *testing.T) {
+		result := inlineQueryResult("b", Gif{FileID: "b", Type: MediaTypeGif})
+		assert.Equal(t, result, NewInlineQueryResultCachedGif("b", "b"))
+	})
+
+	t.Run("title and caption", func(t *testing.T) {
+		result := inlineQueryResult("d", Gif{FileID: "d", Title: "Cat", Caption: "lol", AltText: "a cat falling over"})
+		expected := NewInlineQueryResultCachedMpeg4Gif("d", "d")
+		expected.Title = "Cat"
+		expected.Caption = "lol"
+		assert.Equal(t, result, expected)
+	})
+
+	t.Run("alt text as title", func(t *testing.T) {
+		result := inlineQueryResult("e", Gif{FileID: "e", Type: MediaTypeGif, AltText: "a cat falling over"})
+		expected := NewInlineQueryResultCachedGif("e", "e")
+		expected.Title = "a cat falling over"
+		assert.Equal(t, result, expected)
+	})
+
+	t.Run("video", func(t *testing.T) {
+		result := inlineQueryResult("c", Gif{FileID: "c", Type: MediaTypeVideo, Keywords: "funny cat"})
+		assert.Equal(t, result, NewInlineQueryResultCachedVideo("c", "c", "funny cat"))
+	})
+}
+
+func TestInlineQueryResults(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keeps order and first copy", func(t *testing.T) {
+		gifs := []Gif{
+			{Pack: "A", FileID: "c", FileUniqueID: "c", Title: "first"},
+			{Pack: "A", FileID: "a", FileUniqueID: "a"},
+			{Pack: "B", FileID: "c2", FileUniqueID: "c", Title: "second"},
+			{Pack: "B", FileID: "b", FileUniqueID: "b"},
+		}
+
+		results := inlineQueryResults(gifs)
+		assert.Equal(t, len(results), 3)
+		assert.Equal(t, results[0].(InlineQueryResultCachedMpeg4Gif).Title, "first")
+		assert.Equal(t, results[1].(InlineQueryResultCachedMpeg4Gif).ID, "a")
+		assert.Equal(t, results[2].(InlineQueryResultCachedMpeg4Gif).ID, "b")
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		var gifs []Gif
+		for i := 0; i < maxInlineResults+10; i++ {
+			gifs = append(gifs, Gif{FileID: search.Atom(fmt.Sprint(i))})
+		}
+
+		assert.Equal(t, len(inlineQueryResults(gifs)), maxInlineResults)
+	})
+}
+
+func TestWithFileUniqueIDs(t *testing.T) {
+	t.Parallel()
+
+	update := []byte(`{
+		"update_id": 1,
+		"message": {
+			"message_id": 2,
+			"animation": {"file_id": "a", "file_unique_id": "ua"},
+			"document": {"file_id": "a", "file_unique_id": "ua"},
+			"reply_to_message": {
+				"message_id": 1,
+				"photo": [{"file_id": "b", "file_unique_id": "ub"}, {"file_id": "c"}]
+			}
+		}
+	}`)
+	ctx := withFileUniqueIDs(context.Background(), update)
+
+	assert.Equal(t, fileUniqueID(ctx, "a"), "ua")
+	assert.Equal(t, fileUniqueID(ctx, "b"), "ub")
+	assert.Equal(t, fileUniqueID(ctx, "c"), "")
+	assert.Equal(t, mediaID(ctx, "a"), "ua")
+	assert.Equal(t, mediaID(ctx, "c"), "c")
+}
+
+func TestGifID(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, gifID(Gif{FileID: "a", FileUniqueID: "ua"}), "ua")
+	assert.Equal(t, gifID(Gif{FileID: "a"}), "a")
+	assert.Equal(t, gifKey("foo", "ua"), "FOO:ua")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+)
+
+// Role represents the role a user has in a gif pack. Roles are ordered, and each role can do everything the roles below
+// it can do.
+type Role int
+
+// roles
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleAdder
+	RoleEditor
+	RoleOwner
+)
+
+var roleNames = map[Role]string{
+	RoleNone:   "none",
+	RoleViewer: "viewer",
+	RoleAdder:  "adder",
+	RoleEditor: "editor",
+	RoleOwner:  "owner",
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// ParseRole returns the Role called name. ok will be false if there is no such role.
+func ParseRole(name string) (role Role, ok bool) {
+	name = strings.ToLower(name)
+	for r, n := range roleNames {
+		if n == name {
+			return r, true
+		}
+	}
+
+	return RoleNone, false
+}
+
+// Action represents something a user can do to a gif pack.
+type Action int
+
+// actions
+const (
+	ActionView Action = iota
+	ActionAddGif
+	ActionEditGif
+	ActionDeleteGif
+	ActionManageMembers
+	ActionManageSettings
+	ActionDeletePack
+)
+
+// requiredRoles is the minimum role needed to perform each action.
+var requiredRoles = map[Action]Role{
+	ActionView:           RoleNone,
+	ActionAddGif:         RoleAdder,
+	ActionEditGif:        RoleEditor,
+	ActionDeleteGif:      RoleEditor,
+	ActionManageMembers:  RoleOwner,
+	ActionManageSettings: RoleOwner,
+	ActionDeletePack:     RoleOwner,
+}
+
+// PackRole returns the role userID has in pack.
+func PackRole(pack Pack, userID int) Role {
+	if userID == pack.Creator {
+		return RoleOwner
+	}
+
+	members := []struct {
+		role  Role
+		users []int
+	}{
+		{RoleEditor, pack.Contributors},
+		{RoleAdder, pack.Adders},
+		{RoleViewer, pack.Viewers},
+	}
+
+	for _, m := range members {
+		for _, u := range m.users {
+			if userID == u {
+				return m.role
+			}
+		}
+	}
+
+	return RoleNone
+}
+
+// Can returns true if userID is allowed to perform action on pack. All permission checks should go through Can.
+func Can(pack Pack, userID int, action Action) bool {
+	required, ok := requiredRoles[action]
+	if !ok {
+		return false
+	}
+
+	// only members can see the contents of private packs. Invites are not tied to a user, so whoever holds one becomes
+	// a member by redeeming it, which also subscribes them to the pack.
+	if action == ActionView && pack.Visibility == VisibilityPrivate {
+		required = RoleViewer
+	}
+
+	return PackRole(pack, userID) >= required
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackRole(t *testing.T) {
+	t.Parallel()
+
+	pack := Pack{
+		Name:         "pack1",
+		Creator:      1,
+		Contributors: []int{2},
+		Adders:       []int{3},
+		Viewers:      []int{4},
+	}
+
+	assert.Equal(t, PackRole(pack, 1), RoleOwner)
+	assert.Equal(t, PackRole(pack, 2), RoleEditor)
+	assert.Equal(t, PackRole(pack, 3), RoleAdder)
+	assert.Equal(t, PackRole(pack, 4), RoleViewer)
+	assert.Equal(t, PackRole(pack, 5), RoleNone)
+}
+
+func TestCan(t *testing.T) {
+	t.Parallel()
+
+	pack := Pack{
+		Name:         "pack1",
+		Creator:      1,
+		Contributors: []int{2},
+		Adders:       []int{3},
+		Viewers:      []int{4},
+	}
+
+	testCases := []struct {
+		name    string
+		userID  int
+		action  Action
+		allowed bool
+	}{
+		{"owner delete pack", 1, ActionDeletePack, true},
+		{"owner manage members", 1, ActionManageMembers, true},
+		{"editor manage members", 2, ActionManageMembers, false},
+		{"editor delete gif", 2, ActionDeleteGif, true},
+		{"editor edit gif", 2, ActionEditGif, true},
+		{"adder add gif", 3, ActionAddGif, true},
+		{"adder edit gif", 3, ActionEditGif, false},
+		{"adder delete gif", 3, ActionDeleteGif, false},
+		{"viewer add gif", 4, ActionAddGif, false},
+		{"viewer view", 4, ActionView, true},
+		{"stranger add gif", 5, ActionAddGif, false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, Can(pack, tc.userID, tc.action), tc.allowed, tc.name)
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	t.Parallel()
+
+	role, ok := ParseRole("Editor")
+	assert.True(t, ok)
+	assert.Equal(t, role, RoleEditor)
+
+	_, ok = ParseRole("admin")
+	assert.False(t, ok)
+}
+
+func TestCanViewPrivate(t *testing.T) {
+	t.Parallel()
+
+	pack := Pack{
+		Name:         "pack1",
+		Creator:      1,
+		Contributors: []int{2},
+		Viewers:      []int{3},
+		Visibility:   VisibilityPrivate,
+	}
+
+	assert.True(t, Can(pack, 1, ActionView))
+	assert.True(t, Can(pack, 2, ActionView))
+	assert.True(t, Can(pack, 3, ActionView))
+	assert.False(t, Can(pack, 4, ActionView))
+
+	pack.Visibility = VisibilityUnlisted
+	assert.True(t, Can(pack, 4, ActionView))
+}
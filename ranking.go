@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// how much a search term matching each field of a gif counts towards its relevance. Titles are chosen to describe
+// the whole gif, so they count for more than a single keyword.
+const (
+	keywordWeight = 1
+	titleWeight   = 2
+)
+
+// relevance returns how well gif matches terms, which is the number of terms found in its keywords and title, weighted
+// by field. terms must already be normalised.
+func relevance(gif Gif, terms []string) int {
+	keywords := make(map[string]bool)
+	for _, term := range strings.Fields(gif.Terms) {
+		keywords[term] = true
+	}
+
+	title := make(map[string]bool)
+	for _, term := range strings.Fields(gif.TitleTerms) {
+		title[term] = true
+	}
+
+	score := 0
+	for _, term := range terms {
+		if keywords[term] {
+			score += keywordWeight
+		}
+		if title[term] {
+			score += titleWeight
+		}
+	}
+
+	return score
+}
+
+// rankGifs sorts gifs by their relevance to the terms for their pack, most relevant first. Equally relevant gifs are
+// sorted by when they were added, newest first, and otherwise keep their order.
+func rankGifs(gifs []Gif, terms map[string][]string) {
+	scores := make([]int, len(gifs))
+	for i, gif := range gifs {
+		scores[i] = relevance(gif, terms[strings.ToUpper(string(gif.Pack))])
+	}
+
+	sort.Stable(gifsByRelevance{gifs, scores})
+}
+
+// gifsByRelevance sorts gifs along with their relevance scores.
+type gifsByRelevance struct {
+	gifs   []Gif
+	scores []int
+}
+
+func (g gifsByRelevance) Len() int {
+	return len(g.gifs)
+}
+
+func (g gifsByRelevance) Less(i, j int) bool {
+	if g.scores[i] != g.scores[j] {
+		return g.scores[i] > g.scores[j]
+	}
+
+	return g.gifs[i].Added.After(g.gifs[j].Added)
+}
+
+func (g gifsByRelevance) Swap(i, j int) {
+	g.gifs[i], g.gifs[j] = g.gifs[j], g.gifs[i]
+	g.scores[i], g.scores[j] = g.scores[j], g.scores[i]
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelevance(t *testing.T) {
+	t.Parallel()
+
+	gif := Gif{Terms: "cat funni", TitleTerms: "cat"}
+
+	assert.Equal(t, relevance(gif, nil), 0)
+	assert.Equal(t, relevance(gif, []string{"dog"}), 0)
+	assert.Equal(t, relevance(gif, []string{"funni"}), keywordWeight)
+	assert.Equal(t, relevance(gif, []string{"cat"}), keywordWeight+titleWeight)
+	assert.Equal(t, relevance(gif, []string{"cat", "funni"}), 2*keywordWeight+titleWeight)
+}
+
+func TestRankGifs(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	gifs := []Gif{
+		{Pack: "A", FileID: "old", Terms: "cat", Added: now.Add(-time.Hour)},
+		{Pack: "A", FileID: "none", Terms: "dog"},
+		{Pack: "A", FileID: "new", Terms: "cat", Added: now},
+		{Pack: "A", FileID: "both", Terms: "cat funni"},
+		{Pack: "A", FileID: "title", Terms: "dog", TitleTerms: "cat"},
+		{Pack: "B", FileID: "synonym", Terms: "kitti", Added: now},
+	}
+	terms := map[string][]string{
+		"A": {"cat", "funni"},
+		"B": {"cat", "kitti"},
+	}
+
+	rankGifs(gifs, terms)
+
+	var order []string
+	for _, gif := range gifs {
+		order = append(order, string(gif.FileID))
+	}
+	assert.Equal(t, order, []string{"both", "title", "new", "synonym", "old", "none"})
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/search"
+)
+
+// reorganisation errors
+var (
+	ErrGifNotFound = errors.New("gif not found")
+	ErrSamePack    = errors.New("same pack")
+)
+
+// CopyGif copies the gif with id from source to target, keeping its keywords. userID must be able to edit gifs in both
+// packs. Returns true if the gif was copied, false if it was already in target. err will be ErrGifNotFound if the gif
+// is not in source.
+func CopyGif(ctx context.Context, source, target string, userID int, id string) (bool, error) {
+	return copyGif(ctx, source, target, userID, id, false)
+}
+
+// MoveGif moves the gif with id from source to target, keeping its keywords. userID must be able to edit gifs in both
+// packs. Returns true if the gif was moved, false if it was already in target, in which case its keywords are added to
+// the gif in target and it is removed from source. err will be ErrGifNotFound if the gif is not in source.
+func MoveGif(ctx context.Context, source, target string, userID int, id string) (bool, error) {
+	return copyGif(ctx, source, target, userID, id, true)
+}
+
+func copyGif(ctx context.Context, source, target string, userID int, id string, move bool) (bool, error) {
+	// validate pack names
+	if !packNameRegex.MatchString(source) || !packNameRegex.MatchString(target) {
+		return false, ErrInvalidName
+	}
+
+	// normalise pack names
+	source = strings.ToUpper(source)
+	target = strings.ToUpper(target)
+
+	if source == target {
+		return false, ErrSamePack
+	}
+
+	// check that user can edit gifs in both packs
+	for _, packName := range []string{source, target} {
+		pack, err := GetPack(ctx, packName)
+		if err != nil {
+			return false, err
+		}
+
+		if !HasEditPermissions(pack, userID) {
+			return false, ErrNotAllowed
+		}
+	}
+
+	gif, err := GetGif(ctx, source, id)
+	if err != nil {
+		if err == ErrNotFound {
+			return false, ErrGifNotFound
+		}
+
+		return false, err
+	}
+
+	index, err := search.Open(gifsIndex)
+	if err != nil {
+		return false, err
+	}
+
+	// check if gif is already in target
+	existing, err := GetGif(ctx, target, id)
+	if err != nil {
+		if err != ErrNotFound {
+			return false, err
+		}
+	} else {
+		if !move {
+			return false, nil
+		}
+
+		// a move still takes the gif out of source, so keep its keywords in target
+		existing.Keywords = mergeKeywords(existing.Keywords, gif.Keywords)
+		existing.Terms = keywordTerms(existing.Keywords)
+		_, err = index.Put(ctx, gifKey(target, id), &existing)
+		if err != nil {
+			return false, err
+		}
+
+		err = index.Delete(ctx, gifKey(source, id))
+		if err != nil {
+			return false, err
+		}
+
+		err = updatePackCounts(ctx, source, -1, 0)
+		if err != nil {
+			return false, err
+		}
+
+		return false, nil
+	}
+
+	// the search index has no transactions, so a move adds the gif to target first and takes it back out if it could
+	// not be removed from source
+	gif.Pack = search.Atom(target)
+	gif.Added = time.Now()
+	targetKey := gifKey(target, id)
+	_, err = index.Put(ctx, targetKey, &gif)
+	if err != nil {
+		return false, err
+	}
+
+	if move {
+		err = index.Delete(ctx, gifKey(source, id))
+		if err != nil {
+			if err := index.Delete(ctx, targetKey); err != nil {
+				return false, errors.Wrap(err, "failed to roll back move")
+			}
+
+			return false, err
+		}
+
+		err = updatePackCounts(ctx, source, -1, 0)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = updatePackCounts(ctx, target, 1, 0)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// MergeResult describes what happened when two packs were merged.
+type MergeResult struct {
+	// Gifs is the number of gifs moved into the target pack
+	Gifs int
+	// Merged is the number of gifs which were already in the target pack and had their keywords merged
+	Merged int
+	// Subscribers is the number of subscribers moved to the target pack
+	Subscribers int
+}
+
+// MergePacks moves every gif in source into target and then soft deletes source. Gifs which are in both packs keep the
+// keywords from both. Subscribers of source are subscribed to target instead, unless they already were. userID must
+// own both packs.
+func MergePacks(ctx context.Context, source, target string, userID int) (MergeResult, error) {
+	// validate pack names
+	if !packNameRegex.MatchString(source) || !packNameRegex.MatchString(target) {
+		return MergeResult{}, ErrInvalidName
+	}
+
+	// normalise pack names
+	source = strings.ToUpper(source)
+	target = strings.ToUpper(target)
+
+	if source == target {
+		return MergeResult{}, ErrSamePack
+	}
+
+	// check that user owns both packs
+	for _, packName := range []string{source, target} {
+		pack, err := GetPack(ctx, packName)
+		if err != nil {
+			return MergeResult{}, err
+		}
+
+		if PackRole(pack, userID) != RoleOwner {
+			return MergeResult{}, ErrNotAllowed
+		}
+	}
+
+	var result MergeResult
+	index, err := search.Open(gifsIndex)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	// collect the gifs first so that the index is not changed while searching it
+	var keys []string
+	var gifs []Gif
+	for t := index.Search(ctx, "Pack = "+source, nil); ; {
+		var gif Gif
+		key, err := t.Next(&gif)
+		if err == search.Done {
+			break
+		}
+		if err != nil {
+			return MergeResult{}, err
+		}
+
+		keys = append(keys, key)
+		gifs = append(gifs, gif)
+	}
+
+	for i, gif := range gifs {
+		id := gifID(gif)
+		existing, err := GetGif(ctx, target, id)
+		if err != nil {
+			if err != ErrNotFound {
+				return MergeResult{}, err
+			}
+
+			gif.Added = time.Now()
+			result.Gifs++
+		} else {
+			// keep the gif in target with its own details, only adding the keywords from source
+			existing.Keywords = mergeKeywords(existing.Keywords, gif.Keywords)
+			existing.Terms = keywordTerms(existing.Keywords)
+			gif = existing
+			result.Merged++
+		}
+
+		gif.Pack = search.Atom(target)
+		_, err = index.Put(ctx, gifKey(target, id), &gif)
+		if err != nil {
+			return MergeResult{}, err
+		}
+
+		err = index.Delete(ctx, keys[i])
+		if err != nil {
+			return MergeResult{}, err
+		}
+	}
+
+	// move subscriptions
+	var subscriptions []Subscription
+	subscriptionKeys, err := datastore.NewQuery(subscriptionKind).Filter("Pack =", source).GetAll(ctx, &subscriptions)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	for _, s := range subscriptions {
+		key := datastore.NewKey(ctx, subscriptionKind, fmt.Sprintf("%d:%s", s.UserID, target), 0, nil)
+		var existing Subscription
+		err := datastore.Get(ctx, key, &existing)
+		if err == nil {
+			continue
+		}
+		if err != datastore.ErrNoSuchEntity {
+			return MergeResult{}, err
+		}
+
+		subscription := Subscription{
+			UserID: s.UserID,
+			Pack:   target,
+		}
+
+		_, err = datastore.Put(ctx, key, &subscription)
+		if err != nil {
+			return MergeResult{}, err
+		}
+
+		result.Subscribers++
+	}
+
+	err = datastore.DeleteMulti(ctx, subscriptionKeys)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	err = updatePackCounts(ctx, target, result.Gifs, result.Subscribers)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	err = updatePackCounts(ctx, source, -len(gifs), -len(subscriptions))
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	err = SoftDeletePack(ctx, source, userID)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	return result, nil
+}
+
+// mergeKeywords returns the keywords in a followed by the keywords in b which are not already in a, ignoring case.
+func mergeKeywords(a, b string) string {
+	keywords := strings.Fields(a)
+	seen := make(map[string]bool)
+	for _, keyword := range keywords {
+		seen[strings.ToLower(keyword)] = true
+	}
+
+	for _, keyword := range strings.Fields(b) {
+		if !seen[strings.ToLower(keyword)] {
+			seen[strings.ToLower(keyword)] = true
+			keywords = append(keywords, keyword)
+		}
+	}
+
+	return strings.Join(keywords, " ")
+}
+
+// ForkPack creates a new pack called packName owned by userID with a copy of every gif in source, keeping their
+// keywords and visibility. userID must be able to see source. Private packs can only be forked by their editors, since
+// the owner of a fork can make it public. Returns true if the fork was created, false if a pack called packName already
+// exists.
+func ForkPack(ctx context.Context, source, packName string, userID int) (bool, error) {
+	// validate pack names
+	if !packNameRegex.MatchString(source) || !packNameRegex.MatchString(packName) {
+		return false, ErrInvalidName
+	}
+
+	// normalise pack name
+	source = strings.ToUpper(source)
+
+	pack, err := GetPack(ctx, source)
+	if err != nil {
+		return false, err
+	}
+
+	if !Can(pack, userID, ActionView) {
+		return false, ErrNotFound
+	}
+
+	if packVisibility(pack) == VisibilityPrivate && !Can(pack, userID, ActionEditGif) {
+		return false, ErrNotAllowed
+	}
+
+	created, err := NewPack(ctx, packName, userID)
+	if err != nil || !created {
+		return false, err
+	}
+
+	fork, err := GetPack(ctx, packName)
+	if err != nil {
+		return false, err
+	}
+
+	fork.ForkedFrom = pack.Name
+	fork.Synonyms = pack.Synonyms
+	fork.Visibility = packVisibility(pack)
+	err = SetPack(ctx, &fork)
+	if err != nil {
+		return false, err
+	}
+
+	index, err := search.Open(gifsIndex)
+	if err != nil {
+		return false, err
+	}
+
+	target := strings.ToUpper(packName)
+	now := time.Now()
+	gifs := 0
+	for t := index.Search(ctx, "Pack = "+source, nil); ; {
+		var gif Gif
+		_, err := t.Next(&gif)
+		if err == search.Done {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+
+		gif.Pack = search.Atom(target)
+		gif.Added = now
+		_, err = index.Put(ctx, gifKey(target, gifID(gif)), &gif)
+		if err != nil {
+			return false, err
+		}
+
+		gifs++
+	}
+
+	err = updatePackCounts(ctx, target, gifs, 0)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
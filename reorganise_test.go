@@ -0,0 +1,365 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/appengine/aetest"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestMergeKeywords(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disjoint", func(t *testing.T) {
+		assert.Equal(t, mergeKeywords("funny cat", "lol"), "funny cat lol")
+	})
+
+	t.Run("overlapping", func(t *testing.T) {
+		assert.Equal(t, mergeKeywords("funny cat", "Cat  dog funny"), "funny cat dog")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		assert.Equal(t, mergeKeywords("", "cat"), "cat")
+	})
+}
+
+func TestCopyGif(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := NewContext(&aetest.Options{
+		StronglyConsistentDatastore: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	// user 2 can edit source but can only view target
+	packs := []Pack{
+		{Name: "source", Creator: 1, Contributors: []int{2}},
+		{Name: "target", Creator: 1, Viewers: []int{2}},
+	}
+	for i := range packs {
+		key := datastore.NewKey(ctx, packKind, strings.ToUpper(packs[i].Name), 0, nil)
+		_, err := datastore.Put(ctx, key, &packs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gifs := []struct {
+		pack string
+		gif  Gif
+	}{
+		{"source", Gif{Pack: "SOURCE", FileID: "file1", FileUniqueID: "gif1", Keywords: "cat"}},
+		{"source", Gif{Pack: "SOURCE", FileID: "file2", FileUniqueID: "gif2", Keywords: "dog"}},
+		{"target", Gif{Pack: "TARGET", FileID: "file2", FileUniqueID: "gif2", Keywords: "puppy"}},
+	}
+	for _, g := range gifs {
+		_, err := NewGif(ctx, g.pack, 1, g.gif)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("same pack", func(t *testing.T) {
+		_, err := CopyGif(ctx, "source", "SOURCE", 1, "gif1")
+		assert.Equal(t, err, ErrSamePack)
+	})
+
+	t.Run("not an editor of target", func(t *testing.T) {
+		_, err := CopyGif(ctx, "source", "target", 2, "gif1")
+		assert.Equal(t, err, ErrNotAllowed)
+	})
+
+	t.Run("not an editor of source", func(t *testing.T) {
+		_, err := CopyGif(ctx, "target", "source", 2, "gif2")
+		assert.Equal(t, err, ErrNotAllowed)
+	})
+
+	t.Run("gif not in source", func(t *testing.T) {
+		_, err := CopyGif(ctx, "source", "target", 1, "nonexistent")
+		assert.Equal(t, err, ErrGifNotFound)
+	})
+
+	t.Run("already in target", func(t *testing.T) {
+		copied, err := CopyGif(ctx, "source", "target", 1, "gif2")
+		assert.Equal(t, err, nil)
+		assert.False(t, copied)
+
+		gif, err := GetGif(ctx, "target", "gif2")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, gif.Keywords, "puppy")
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		copied, err := CopyGif(ctx, "source", "target", 1, "gif1")
+		assert.Equal(t, err, nil)
+		assert.True(t, copied)
+
+		gif, err := GetGif(ctx, "target", "gif1")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, gif.Keywords, "cat")
+
+		_, err = GetGif(ctx, "source", "gif1")
+		assert.Equal(t, err, nil)
+
+		target, err := GetPack(ctx, "target")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, target.Gifs, 2)
+	})
+}
+
+func TestMoveGif(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := NewContext(&aetest.Options{
+		StronglyConsistentDatastore: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	packs := []Pack{
+		{Name: "source", Creator: 1},
+		{Name: "target", Creator: 1},
+	}
+	for i := range packs {
+		key := datastore.NewKey(ctx, packKind, strings.ToUpper(packs[i].Name), 0, nil)
+		_, err := datastore.Put(ctx, key, &packs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gifs := []struct {
+		pack string
+		gif  Gif
+	}{
+		{"source", Gif{Pack: "SOURCE", FileID: "file1", FileUniqueID: "gif1", Keywords: "cat"}},
+		{"source", Gif{Pack: "SOURCE", FileID: "file2", FileUniqueID: "gif2", Keywords: "dog"}},
+		{"target", Gif{Pack: "TARGET", FileID: "file2", FileUniqueID: "gif2", Keywords: "puppy"}},
+	}
+	for _, g := range gifs {
+		_, err := NewGif(ctx, g.pack, 1, g.gif)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		moved, err := MoveGif(ctx, "source", "target", 1, "gif1")
+		assert.Equal(t, err, nil)
+		assert.True(t, moved)
+
+		gif, err := GetGif(ctx, "target", "gif1")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, gif.Keywords, "cat")
+
+		_, err = GetGif(ctx, "source", "gif1")
+		assert.Equal(t, err, ErrNotFound)
+	})
+
+	t.Run("already in target", func(t *testing.T) {
+		moved, err := MoveGif(ctx, "source", "target", 1, "gif2")
+		assert.Equal(t, err, nil)
+		assert.False(t, moved)
+
+		gif, err := GetGif(ctx, "target", "gif2")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, gif.Keywords, "puppy dog")
+
+		_, err = GetGif(ctx, "source", "gif2")
+		assert.Equal(t, err, ErrNotFound)
+
+		source, err := GetPack(ctx, "source")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, source.Gifs, 0)
+	})
+}
+
+func TestMergePacks(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := NewContext(&aetest.Options{
+		StronglyConsistentDatastore: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	packs := []Pack{
+		{Name: "source", Creator: 1},
+		{Name: "target", Creator: 1},
+		{Name: "other", Creator: 2},
+	}
+	for i := range packs {
+		key := datastore.NewKey(ctx, packKind, strings.ToUpper(packs[i].Name), 0, nil)
+		_, err := datastore.Put(ctx, key, &packs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// gif2 is in both packs
+	gifs := []struct {
+		pack string
+		gif  Gif
+	}{
+		{"source", Gif{Pack: "SOURCE", FileID: "file1", FileUniqueID: "gif1", Keywords: "cat"}},
+		{"source", Gif{Pack: "SOURCE", FileID: "file2", FileUniqueID: "gif2", Keywords: "dog"}},
+		{"target", Gif{Pack: "TARGET", FileID: "file3", FileUniqueID: "gif2", Keywords: "puppy", Title: "Puppy", Caption: "woof"}},
+	}
+	for _, g := range gifs {
+		_, err := NewGif(ctx, g.pack, 1, g.gif)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// user 3 is subscribed to both packs and user 4 only to the source
+	subscriptions := []Subscription{
+		{UserID: 3, Pack: "SOURCE"},
+		{UserID: 4, Pack: "SOURCE"},
+		{UserID: 3, Pack: "TARGET"},
+	}
+	for _, s := range subscriptions {
+		_, err := Subscribe(ctx, s.Pack, s.UserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("same pack", func(t *testing.T) {
+		_, err := MergePacks(ctx, "source", "SOURCE", 1)
+		assert.Equal(t, err, ErrSamePack)
+	})
+
+	t.Run("not owner of both", func(t *testing.T) {
+		_, err := MergePacks(ctx, "source", "other", 1)
+		assert.Equal(t, err, ErrNotAllowed)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		result, err := MergePacks(ctx, "source", "target", 1)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, result.Gifs, 1)
+		assert.Equal(t, result.Merged, 1)
+		assert.Equal(t, result.Subscribers, 1)
+
+		source, err := GetPack(ctx, "source")
+		assert.Equal(t, err, ErrDeleted)
+		assert.True(t, source.Deleted)
+
+		gif, err := GetGif(ctx, "target", "gif1")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, gif.Keywords, "cat")
+
+		// the gif in target keeps its own details
+		gif, err = GetGif(ctx, "target", "gif2")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, gif.Keywords, "puppy dog")
+		assert.Equal(t, gif.Title, "Puppy")
+		assert.Equal(t, gif.Caption, "woof")
+		assert.Equal(t, string(gif.FileID), "file3")
+
+		for _, id := range []string{"gif1", "gif2"} {
+			_, err = GetGif(ctx, "source", id)
+			assert.Equal(t, err, ErrNotFound)
+		}
+
+		target, err := GetPack(ctx, "target")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, target.Gifs, 2)
+
+		subs, err := MySubscriptions(ctx, 4)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(subs), 1)
+		assert.Equal(t, subs[0].Pack, "TARGET")
+
+		subs, err = MySubscriptions(ctx, 3)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(subs), 1)
+	})
+}
+
+func TestForkPack(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := NewContext(&aetest.Options{
+		StronglyConsistentDatastore: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	packs := []Pack{
+		{Name: "public", Creator: 1, Visibility: VisibilityPublic},
+		{Name: "private", Creator: 1, Contributors: []int{4}, Viewers: []int{3}, Visibility: VisibilityPrivate},
+	}
+	for i := range packs {
+		key := datastore.NewKey(ctx, packKind, strings.ToUpper(packs[i].Name), 0, nil)
+		_, err := datastore.Put(ctx, key, &packs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = NewGif(ctx, "public", 1, Gif{Pack: "PUBLIC", FileID: "file1", FileUniqueID: "gif1", Keywords: "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("private", func(t *testing.T) {
+		_, err := ForkPack(ctx, "private", "mine", 2)
+		assert.Equal(t, err, ErrNotFound)
+	})
+
+	t.Run("private as a viewer", func(t *testing.T) {
+		_, err := ForkPack(ctx, "private", "viewers", 3)
+		assert.Equal(t, err, ErrNotAllowed)
+
+		_, err = GetPack(ctx, "viewers")
+		assert.Equal(t, err, ErrNotFound)
+	})
+
+	t.Run("private as an editor", func(t *testing.T) {
+		created, err := ForkPack(ctx, "private", "editors", 4)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, created, true)
+
+		fork, err := GetPack(ctx, "editors")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, fork.Creator, 4)
+		assert.Equal(t, fork.Visibility, VisibilityPrivate)
+	})
+
+	t.Run("name taken", func(t *testing.T) {
+		created, err := ForkPack(ctx, "public", "private", 2)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, created, false)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		created, err := ForkPack(ctx, "public", "mine", 2)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, created, true)
+
+		fork, err := GetPack(ctx, "mine")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, fork.Creator, 2)
+		assert.Equal(t, fork.ForkedFrom, "public")
+		assert.Equal(t, fork.Visibility, VisibilityPublic)
+		assert.Equal(t, fork.Gifs, 1)
+
+		gif, err := GetGif(ctx, "mine", "gif1")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, gif.Keywords, "cat")
+		assert.Equal(t, string(gif.Pack), "MINE")
+	})
+}
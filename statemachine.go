@@ -2,6 +2,10 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/go-telegram-bot-api/telegram-bot-api"
 	"golang.org/x/net/context"
 	"google.golang.org/appengine/search"
@@ -9,7 +13,7 @@ import (
 
 // states
 const (
-	stateNone                    = iota
+	stateNone = iota
 	stateNewGifWaitPackName
 	stateNewGifWaitGif
 	stateNewGifWaitKeywords
@@ -19,19 +23,72 @@ const (
 	stateDeleteGifWaitPackName
 	stateDeleteGifWaitGif
 	stateDeletePackWaitPackName
+	stateInviteWaitPackName
+	stateTransferPackWaitPackName
+	stateTransferPackWaitNewOwner
+	stateQuickAddWaitPack
+	stateCopyGifWaitSource
+	stateCopyGifWaitGif
+	stateCopyGifWaitTarget
+	stateGifWaitTitle
+	stateGifWaitCaption
+	stateGifWaitAltText
+	stateEditGifWaitPackName
+	stateEditGifWaitGif
+	stateEditGifWaitKeywords
 )
 
 // Transducers is a map associating states with their respective Transducer
 var Transducers = map[int]Transducer{
-	stateNewGifWaitPackName:      stateNewGifWaitPackNameTransducer,
-	stateNewGifWaitGif:           newGifWaitGifTransducer,
-	stateNewGifWaitKeywords:      newGifWaitKeywordsTransducer,
-	stateNewPackWaitPackName:     newPackWaitPackNameTransducer,
-	stateSubscribeWaitPackName:   subscibeWaitPackNameTransducer,
-	stateUnsubscribeWaitPackName: unsubscribeWaitPackNameTransducer,
-	stateDeleteGifWaitPackName:   deleteGifWaitPackNameTransduce,
-	stateDeleteGifWaitGif:        deleteGifWaitGifTransducer,
-	stateDeletePackWaitPackName:  deletePackWaitPackNameTransducer,
+	stateNewGifWaitPackName:       stateNewGifWaitPackNameTransducer,
+	stateNewGifWaitGif:            newGifWaitGifTransducer,
+	stateNewGifWaitKeywords:       newGifWaitKeywordsTransducer,
+	stateNewPackWaitPackName:      newPackWaitPackNameTransducer,
+	stateSubscribeWaitPackName:    subscibeWaitPackNameTransducer,
+	stateUnsubscribeWaitPackName:  unsubscribeWaitPackNameTransducer,
+	stateDeleteGifWaitPackName:    deleteGifWaitPackNameTransduce,
+	stateDeleteGifWaitGif:         deleteGifWaitGifTransducer,
+	stateDeletePackWaitPackName:   deletePackWaitPackNameTransducer,
+	stateInviteWaitPackName:       inviteWaitPackNameTransducer,
+	stateTransferPackWaitPackName: transferPackWaitPackNameTransducer,
+	stateTransferPackWaitNewOwner: transferPackWaitNewOwnerTransducer,
+	stateQuickAddWaitPack:         quickAddTransducer,
+	stateCopyGifWaitSource:        copyGifWaitSourceTransducer,
+	stateCopyGifWaitGif:           copyGifWaitGifTransducer,
+	stateCopyGifWaitTarget:        copyGifWaitTargetTransducer,
+	stateGifWaitTitle:             gifDetailsTransducer,
+	stateGifWaitCaption:           gifDetailsTransducer,
+	stateGifWaitAltText:           gifDetailsTransducer,
+	stateEditGifWaitPackName:      editGifWaitPackNameTransducer,
+	stateEditGifWaitGif:           editGifWaitGifTransducer,
+	stateEditGifWaitKeywords:      editGifWaitKeywordsTransducer,
+}
+
+// skippableStates are the states in which /skip can be used to leave something as it is
+var skippableStates = map[int]bool{
+	stateGifWaitTitle:        true,
+	stateGifWaitCaption:      true,
+	stateGifWaitAltText:      true,
+	stateEditGifWaitKeywords: true,
+}
+
+// clearableStates are the states in which /none can be used to clear an optional detail
+var clearableStates = map[int]bool{
+	stateGifWaitTitle:   true,
+	stateGifWaitCaption: true,
+	stateGifWaitAltText: true,
+}
+
+// gifDetailSteps are the optional details asked for in order after the keywords of a gif, both when adding and
+// editing it
+var gifDetailSteps = []struct {
+	state  int
+	key    string
+	prompt string
+}{
+	{stateGifWaitTitle, "title", "a title for this gif"},
+	{stateGifWaitCaption, "caption", "a caption to send along with this gif"},
+	{stateGifWaitAltText, "altText", "some alt text describing this gif for people who can't see it"},
 }
 
 // State errors
@@ -54,9 +111,17 @@ func Transduce(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Mess
 		return err
 	}
 
+	transducer := Transducers[state.State]
+	if transducer == nil {
+		// gifs sent in private chats outside of a conversation can be added to a pack straight away
+		if _, _, ok := messageMedia(message); ok && state.State == stateNone && message.Chat.IsPrivate() {
+			transducer = quickAddTransducer
+		}
+	}
+
 	var action func() error
 	var nextState ConversationState
-	if transducer := Transducers[state.State]; transducer != nil {
+	if transducer != nil {
 		var err error
 		nextState, action, err = transducer(ctx, bot, message, state)
 		if err != nil {
@@ -100,16 +165,19 @@ func stateNewGifWaitPackNameTransducer(ctx context.Context, bot *tgbotapi.BotAPI
 				return state, nil, err
 			}
 		} else {
-			if HasEditPermissions(pack, userID) {
+			if Can(pack, userID, ActionAddGif) {
 				text = "Please send me the gif you want to add to this pack."
+				if state.Data["batch"] == "true" {
+					text = "Please send me the first gif you want to add to this pack. Send /done when you are finished."
+				}
+
+				state.Data["packName"] = packName
 				nextState = ConversationState{
 					State: stateNewGifWaitGif,
-					Data: map[string]string{
-						"packName": packName,
-					},
+					Data:  state.Data,
 				}
 			} else {
-				text = "Oops, it seems like you are not the creator of this pack. Only the pack creator can add gifs to a pack."
+				text = "Oops, it seems like you are not allowed to add gifs to this pack."
 				nextState = state
 			}
 		}
@@ -128,6 +196,13 @@ func stateNewGifWaitPackNameTransducer(ctx context.Context, bot *tgbotapi.BotAPI
 		}
 	}
 
+	err := setPackNamesKeyboard(&reply, message, nextState.State == stateNewGifWaitPackName, nextState, func() ([]string, error) {
+		return userPackNames(ctx, userID, ActionAddGif)
+	})
+	if err != nil {
+		return state, nil, err
+	}
+
 	action := func() error {
 		_, err := bot.Send(reply)
 		if err != nil {
@@ -145,27 +220,31 @@ func newGifWaitGifTransducer(ctx context.Context, bot *tgbotapi.BotAPI, message
 
 	var nextState ConversationState
 	var text string
-	if document := message.Document; document.MimeType == "video/mp4" {
-		_, err := GetGif(ctx, packName, document.FileID)
+	if fileID, mediaType, ok := messageMedia(message); ok {
+		_, err := GetGif(ctx, packName, mediaID(ctx, fileID))
 		if err != nil {
 			if err == ErrNotFound {
 				text = "Alright, now send me some keywords that describe this gif."
+				state.Data["fileID"] = fileID
+				state.Data["fileUniqueID"] = fileUniqueID(ctx, fileID)
+				state.Data["mediaType"] = mediaType
 				nextState = ConversationState{
 					State: stateNewGifWaitKeywords,
-					Data: map[string]string{
-						"packName": packName,
-						"fileID":   document.FileID,
-					},
+					Data:  state.Data,
 				}
 			} else {
 				return state, nil, err
 			}
+		} else if state.Data["batch"] == "true" {
+			text = "That gif is already part of this pack, so I skipped it. Send me the next gif, or /done when you are finished."
+			incrementCount(state.Data, "duplicates")
+			nextState = state
 		} else {
 			text = "Oops, that gif is already part of this pack. Perhaps you wanted to edit its keywords instead?"
 			nextState = state
 		}
 	} else {
-		text = "Oops, I was waiting for you to send me a gif."
+		text = "Oops, I was waiting for you to send me a gif, sticker, photo, video or voice note."
 		nextState = state
 	}
 
@@ -197,24 +276,65 @@ func newGifWaitKeywordsTransducer(ctx context.Context, bot *tgbotapi.BotAPI, mes
 	var nextState ConversationState
 	var text string
 	if keywords := message.Text; keywords != "" {
-		packName := state.Data["packName"]
-		gif := Gif{
-			Pack:     search.Atom(packName),
-			FileID:   search.Atom(state.Data["fileID"]),
-			Keywords: keywords,
-		}
+		state.Data["keywords"] = keywords
+
+		if state.Data["batch"] == "true" {
+			// batches skip the optional details to keep adding gifs quick
+			ok, err := NewGif(ctx, state.Data["packName"], userID, gifFromState(state))
+			if err != nil {
+				// the pack can be deleted or the user's role taken away part way through a batch, which ends it
+				switch err {
+				case ErrNotFound, ErrDeleted:
+					text = "Whoops, that gif pack has been deleted, so I stopped adding gifs to it."
+				case ErrNotAllowed:
+					text = "Oops, it seems like you are no longer allowed to add gifs to this pack, so I stopped adding gifs to it."
+				default:
+					return state, nil, err
+				}
 
-		ok, err := NewGif(ctx, packName, userID, gif)
-		if err != nil {
-			return state, nil, err
-		}
+				finished := ConversationState{State: stateNewGifWaitGif, Data: state.Data}
+				text += " " + batchSummary(finished)
+				nextState = ConversationState{}
+			} else {
+				// keep going with the next gif until the user sends /done
+				if ok {
+					text = "Great! That gif has been added. Send me the next gif, or /done when you are finished."
+					incrementCount(state.Data, "added")
+				} else {
+					text = "That gif is already part of this pack, so I skipped it. Send me the next gif, or /done when you are finished."
+					incrementCount(state.Data, "duplicates")
+				}
 
-		if ok {
-			text = "Great! A new gif has been added to your gif pack."
+				delete(state.Data, "fileID")
+				delete(state.Data, "fileUniqueID")
+				delete(state.Data, "mediaType")
+				delete(state.Data, "keywords")
+				nextState = ConversationState{
+					State: stateNewGifWaitGif,
+					Data:  state.Data,
+				}
+			}
 		} else {
-			text = "Oops, that gif is already part of this pack. You can use /editgif to edit it instead."
+			// save the gif straight away so that it isn't lost if the optional details are never sent, and add the
+			// details to it afterwards
+			var added bool
+			var err error
+			text, added, err = addGifFromState(ctx, userID, state)
+			if err != nil {
+				return state, nil, err
+			}
+
+			if added {
+				state.Data["saved"] = "true"
+				text += " " + gifDetailPrompt(state, 0)
+				nextState = ConversationState{
+					State: gifDetailSteps[0].state,
+					Data:  state.Data,
+				}
+			} else {
+				nextState = ConversationState{}
+			}
 		}
-		nextState = ConversationState{}
 	} else {
 		text = "Oops, I was waiting for you to send me some keywords for this gif."
 		nextState = state
@@ -449,6 +569,13 @@ func unsubscribeWaitPackNameTransducer(ctx context.Context, bot *tgbotapi.BotAPI
 		}
 	}
 
+	err := setPackNamesKeyboard(&reply, message, nextState.State == stateUnsubscribeWaitPackName, nextState, func() ([]string, error) {
+		return subscribedPackNames(ctx, userID)
+	})
+	if err != nil {
+		return state, nil, err
+	}
+
 	action := func() error {
 		_, err := bot.Send(reply)
 		if err != nil {
@@ -500,6 +627,13 @@ func deleteGifWaitPackNameTransduce(ctx context.Context, bot *tgbotapi.BotAPI, m
 		}
 	}
 
+	err := setPackNamesKeyboard(&reply, message, nextState.State == stateDeleteGifWaitPackName, nextState, func() ([]string, error) {
+		return userPackNames(ctx, message.From.ID, ActionDeleteGif)
+	})
+	if err != nil {
+		return state, nil, err
+	}
+
 	action := func() error {
 		_, err := bot.Send(reply)
 		if err != nil {
@@ -517,11 +651,10 @@ func deleteGifWaitGifTransducer(ctx context.Context, bot *tgbotapi.BotAPI, messa
 
 	var text string
 	var nextState ConversationState
-	if document := message.Document; document.MimeType == "video/mp4" {
+	if fileID, _, ok := messageMedia(message); ok {
 		packName := state.Data["packName"]
-		fileID := document.FileID
 
-		ok, err := DeleteGif(ctx, packName, userID, fileID)
+		ok, err := DeleteGif(ctx, packName, userID, mediaID(ctx, fileID))
 		if err != nil {
 			if err == ErrNotFound {
 				text = "Oops! There doesn't seem to be any gif pack with that name."
@@ -570,3 +703,859 @@ func deleteGifWaitGifTransducer(ctx context.Context, bot *tgbotapi.BotAPI, messa
 
 	return nextState, action, nil
 }
+
+func inviteWaitPackNameTransducer(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, state ConversationState) (ConversationState, func() error, error) {
+	userID := message.From.ID
+
+	var text string
+	var nextState ConversationState
+	if packName := message.Text; packName != "" {
+		invite, err := NewInvite(ctx, packName, userID, RoleEditor)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+				nextState = state
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+				nextState = state
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+				nextState = state
+			case ErrNotAllowed:
+				text = "Oops, it seems like you are not the owner of this pack. Only the pack owner can invite people to it."
+				nextState = state
+			default:
+				return state, nil, err
+			}
+		} else {
+			text = fmt.Sprintf("Great! Send this link to the person you want to invite as %s. It can only be used once and expires on %s.\n%s",
+				invite.Role, invite.Expires.Format("2 Jan 2006"), inviteLink(invite))
+			nextState = ConversationState{}
+		}
+	} else {
+		text = "Oops, I was waiting for you to send me the name of the gif pack you want to invite someone to."
+		nextState = state
+	}
+
+	chatID := message.Chat.ID
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+
+		if nextState.State != stateNone {
+			reply.ReplyMarkup = tgbotapi.ForceReply{
+				ForceReply: true,
+				Selective:  true,
+			}
+		}
+	}
+
+	action := func() error {
+		_, err := bot.Send(reply)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	return nextState, action, nil
+}
+
+func transferPackWaitPackNameTransducer(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, state ConversationState) (ConversationState, func() error, error) {
+	userID := message.From.ID
+
+	var text string
+	var nextState ConversationState
+	if packName := message.Text; packName != "" {
+		pack, err := GetPack(ctx, packName)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+				nextState = state
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+				nextState = state
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+				nextState = state
+			default:
+				return state, nil, err
+			}
+		} else {
+			if !Can(pack, userID, ActionManageMembers) {
+				text = "Oops, it seems like you are not the owner of this pack. Only the pack owner can transfer it."
+				nextState = state
+			} else if len(pack.Contributors) == 0 {
+				text = transferPrompt(pack)
+				nextState = ConversationState{}
+			} else {
+				text = transferPrompt(pack)
+				nextState = ConversationState{
+					State: stateTransferPackWaitNewOwner,
+					Data: map[string]string{
+						"packName": packName,
+					},
+				}
+			}
+		}
+	} else {
+		text = "Oops, I was waiting for you to send me the name of the gif pack you want to transfer."
+		nextState = state
+	}
+
+	chatID := message.Chat.ID
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+
+		if nextState.State != stateNone {
+			reply.ReplyMarkup = tgbotapi.ForceReply{
+				ForceReply: true,
+				Selective:  true,
+			}
+		}
+	}
+
+	action := func() error {
+		_, err := bot.Send(reply)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	return nextState, action, nil
+}
+
+func transferPackWaitNewOwnerTransducer(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, state ConversationState) (ConversationState, func() error, error) {
+	userID := message.From.ID
+	packName := state.Data["packName"]
+
+	var text string
+	var nextState ConversationState
+	requested := false
+	newOwner, err := strconv.Atoi(message.Text)
+	if err == nil {
+		err := RequestTransfer(ctx, packName, userID, newOwner)
+		if err != nil {
+			switch err {
+			case ErrNotContributor:
+				text = "Oops, that user is not a contributor to this gif pack. Please send me the user id of one of its contributors."
+				nextState = state
+			case ErrNotAllowed:
+				text = "Oops, it seems like you are no longer the owner of this pack."
+				nextState = ConversationState{}
+			case ErrNotFound, ErrDeleted:
+				text = "Whoops, that gif pack no longer exists."
+				nextState = ConversationState{}
+			default:
+				return state, nil, err
+			}
+		} else {
+			text = "Great! I have asked them to accept ownership of this gif pack. It will be transferred once they do."
+			nextState = ConversationState{}
+			requested = true
+		}
+	} else {
+		text = "Oops, I was waiting for you to send me the user id of the contributor you want to transfer this gif pack to."
+		nextState = state
+	}
+
+	chatID := message.Chat.ID
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+
+		if nextState.State != stateNone {
+			reply.ReplyMarkup = tgbotapi.ForceReply{
+				ForceReply: true,
+				Selective:  true,
+			}
+		}
+	}
+
+	action := func() error {
+		_, err := bot.Send(reply)
+		if err != nil {
+			return err
+		}
+
+		if requested {
+			notifyTransfer(ctx, bot, packName, newOwner)
+		}
+
+		return nil
+	}
+
+	return nextState, action, nil
+}
+
+// incrementCount adds one to the count stored under key in data.
+func incrementCount(data map[string]string, key string) {
+	count, _ := strconv.Atoi(data[key])
+	data[key] = strconv.Itoa(count + 1)
+}
+
+// batchSummary describes how many gifs were added and skipped during a /newgifs session, or that it was cancelled if
+// it ended before a pack was chosen.
+func batchSummary(state ConversationState) string {
+	// nothing has happened yet if the batch ends before a pack is chosen
+	if state.State == stateNewGifWaitPackName {
+		return "Command cancelled!"
+	}
+
+	added, _ := strconv.Atoi(state.Data["added"])
+	duplicates, _ := strconv.Atoi(state.Data["duplicates"])
+
+	text := fmt.Sprintf("All done! %s added to %s.", pluralise(added, "gif was", "gifs were"), state.Data["packName"])
+	if duplicates > 0 {
+		text += fmt.Sprintf(" %s already in the pack and skipped.", pluralise(duplicates, "gif was", "gifs were"))
+	}
+
+	// a gif which was sent without any keywords yet has not been added
+	if state.State == stateNewGifWaitKeywords {
+		text += " The last gif was not added because it had no keywords."
+	}
+
+	return text
+}
+
+// pluralise formats count followed by singular or plural depending on count.
+func pluralise(count int, singular, plural string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, singular)
+	}
+
+	return fmt.Sprintf("%d %s", count, plural)
+}
+
+// parseQuickAddCaption splits a caption like "pack_name: funny cat" into a pack name and keywords. ok will be false if
+// caption is not in that form.
+func parseQuickAddCaption(caption string) (packName, keywords string, ok bool) {
+	i := strings.Index(caption, ":")
+	if i == -1 {
+		return "", "", false
+	}
+
+	packName = strings.TrimSpace(caption[:i])
+	keywords = strings.TrimSpace(caption[i+1:])
+	if packName == "" || keywords == "" || strings.ContainsAny(packName, " \t\n") {
+		return "", "", false
+	}
+
+	return packName, keywords, true
+}
+
+// quickAddTransducer adds a gif sent in a private chat to a pack. If the gif has a caption like "pack_name: funny cat"
+// it is added right away, otherwise the user is asked to pick a pack with the buttons and then send some keywords.
+func quickAddTransducer(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, state ConversationState) (ConversationState, func() error, error) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	reply := tgbotapi.NewMessage(chatID, "")
+	var nextState ConversationState
+	if fileID, mediaType, ok := messageMedia(message); ok {
+		if packName, keywords, ok := parseQuickAddCaption(message.Caption); ok {
+			gif := Gif{
+				Pack:         search.Atom(packName),
+				FileID:       search.Atom(fileID),
+				FileUniqueID: search.Atom(fileUniqueID(ctx, fileID)),
+				Type:         search.Atom(mediaType),
+				Keywords:     keywords,
+			}
+
+			added, err := NewGif(ctx, packName, userID, gif)
+			if err != nil {
+				switch err {
+				case ErrInvalidName:
+					reply.Text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+				case ErrNotFound:
+					reply.Text = "Oops! There doesn't seem to be any gif pack with that name."
+				case ErrDeleted:
+					reply.Text = "Whoops, that gif pack has been deleted."
+				case ErrNotAllowed:
+					reply.Text = "Oops, it seems like you are not allowed to add gifs to this pack."
+				default:
+					return state, nil, err
+				}
+			} else {
+				if added {
+					reply.Text = "Great! That gif has been added to your gif pack."
+				} else {
+					reply.Text = "Don't worry, that gif is already part of this pack."
+				}
+			}
+			nextState = ConversationState{}
+		} else {
+			packNames, err := userPackNames(ctx, userID, ActionAddGif)
+			if err != nil {
+				return state, nil, err
+			}
+
+			if markup, ok := quickAddKeyboard(packNames); ok {
+				reply.Text = "Which gif pack do you want to add this gif to? Next time, you can caption a gif like \"pack_name: funny cat\" to add it in one step."
+				reply.ReplyMarkup = markup
+				nextState = ConversationState{
+					State: stateQuickAddWaitPack,
+					Data: map[string]string{
+						"fileID":       fileID,
+						"fileUniqueID": fileUniqueID(ctx, fileID),
+						"mediaType":    mediaType,
+					},
+				}
+			} else {
+				reply.Text = "Oops, you don't have any gif packs you can add this gif to. Use /newpack to create one."
+				nextState = ConversationState{}
+			}
+		}
+	} else {
+		reply.Text = "Oops, I was waiting for you to pick a gif pack for that gif using the buttons above."
+		nextState = state
+	}
+
+	action := func() error {
+		_, err := bot.Send(reply)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	return nextState, action, nil
+}
+
+// copyVerb returns "move" if state is for /movegif and "copy" if it is for /copygif.
+func copyVerb(state ConversationState) string {
+	if state.Data["move"] == "true" {
+		return "move"
+	}
+
+	return "copy"
+}
+
+func copyGifWaitSourceTransducer(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, state ConversationState) (ConversationState, func() error, error) {
+	userID := message.From.ID
+	verb := copyVerb(state)
+
+	var text string
+	var nextState ConversationState
+	if packName := message.Text; packName != "" {
+		pack, err := GetPack(ctx, packName)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			default:
+				return state, nil, err
+			}
+			nextState = state
+		} else if !HasEditPermissions(pack, userID) {
+			text = fmt.Sprintf("Oops, it seems like you are not allowed to %s gifs out of this pack.", verb)
+			nextState = state
+		} else {
+			text = fmt.Sprintf("Please send me the gif you want to %s.", verb)
+			state.State = stateCopyGifWaitGif
+			state.Data["source"] = pack.Name
+			nextState = state
+		}
+	} else {
+		text = fmt.Sprintf("Oops, I was waiting for you to send me the name of the gif pack you want to %s a gif from.", verb)
+		nextState = state
+	}
+
+	chatID := message.Chat.ID
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+		reply.ReplyMarkup = tgbotapi.ForceReply{
+			ForceReply: true,
+			Selective:  true,
+		}
+	}
+
+	err := setPackNamesKeyboard(&reply, message, nextState.State == stateCopyGifWaitSource, nextState, func() ([]string, error) {
+		return userPackNames(ctx, userID, ActionEditGif)
+	})
+	if err != nil {
+		return state, nil, err
+	}
+
+	action := func() error {
+		_, err := bot.Send(reply)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	return nextState, action, nil
+}
+
+func copyGifWaitGifTransducer(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, state ConversationState) (ConversationState, func() error, error) {
+	userID := message.From.ID
+	verb := copyVerb(state)
+
+	var text string
+	var nextState ConversationState
+	var packNames []string
+	if fileID, _, ok := messageMedia(message); ok {
+		id := mediaID(ctx, fileID)
+		_, err := GetGif(ctx, state.Data["source"], id)
+		if err != nil {
+			if err != ErrNotFound {
+				return state, nil, err
+			}
+
+			text = "Oops, I couldn't find that gif in this pack. Did you send the right one?"
+			nextState = state
+		} else {
+			packNames, err = userPackNames(ctx, userID, ActionEditGif)
+			if err != nil {
+				return state, nil, err
+			}
+
+			text = fmt.Sprintf("Which gif pack do you want to %s this gif to?", verb)
+			state.State = stateCopyGifWaitTarget
+			state.Data["gifID"] = id
+			nextState = state
+		}
+	} else {
+		text = fmt.Sprintf("Oops, I was waiting for you to send me the gif you want to %s.", verb)
+		nextState = state
+	}
+
+	chatID := message.Chat.ID
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+		reply.ReplyMarkup = tgbotapi.ForceReply{
+			ForceReply: true,
+			Selective:  true,
+		}
+	}
+
+	if keyboard, ok := packNamesKeyboard(packNames); ok {
+		reply.ReplyMarkup = keyboard
+	}
+
+	action := func() error {
+		_, err := bot.Send(reply)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	return nextState, action, nil
+}
+
+func copyGifWaitTargetTransducer(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, state ConversationState) (ConversationState, func() error, error) {
+	userID := message.From.ID
+	verb := copyVerb(state)
+
+	var text string
+	var nextState ConversationState
+	if target := message.Text; target != "" {
+		var copied bool
+		var err error
+		if verb == "move" {
+			copied, err = MoveGif(ctx, state.Data["source"], target, userID, state.Data["gifID"])
+		} else {
+			copied, err = CopyGif(ctx, state.Data["source"], target, userID, state.Data["gifID"])
+		}
+
+		nextState = state
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			case ErrSamePack:
+				text = fmt.Sprintf("Oops, that's the pack the gif is already in. Which other gif pack do you want to %s it to?", verb)
+			case ErrNotAllowed:
+				text = fmt.Sprintf("Oops, it seems like you are not allowed to %s gifs into that pack.", verb)
+			case ErrGifNotFound:
+				text = "Whoops, that gif is no longer in the pack."
+				nextState = ConversationState{}
+			default:
+				return state, nil, err
+			}
+		} else {
+			if copied && verb == "move" {
+				text = "Great! That gif has been moved along with its keywords."
+			} else if copied {
+				text = "Great! That gif has been copied along with its keywords."
+			} else if verb == "move" {
+				text = fmt.Sprintf("Don't worry, that gif was already part of that pack. Its keywords have been added to it there and it has been removed from %s.", state.Data["source"])
+			} else {
+				text = "Don't worry, that gif is already part of that pack."
+			}
+			nextState = ConversationState{}
+		}
+	} else {
+		text = fmt.Sprintf("Oops, I was waiting for you to send me the name of the gif pack you want to %s this gif to.", verb)
+		nextState = state
+	}
+
+	chatID := message.Chat.ID
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+
+		if nextState.State != stateNone {
+			reply.ReplyMarkup = tgbotapi.ForceReply{
+				ForceReply: true,
+				Selective:  true,
+			}
+		}
+	}
+
+	err := setPackNamesKeyboard(&reply, message, nextState.State == stateCopyGifWaitTarget, nextState, func() ([]string, error) {
+		return userPackNames(ctx, userID, ActionEditGif)
+	})
+	if err != nil {
+		return state, nil, err
+	}
+
+	action := func() error {
+		_, err := bot.Send(reply)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	return nextState, action, nil
+}
+
+// gifFromState returns the gif being added or edited in state.
+func gifFromState(state ConversationState) Gif {
+	return Gif{
+		Pack:         search.Atom(strings.ToUpper(state.Data["packName"])),
+		FileID:       search.Atom(state.Data["fileID"]),
+		FileUniqueID: search.Atom(state.Data["fileUniqueID"]),
+		Type:         search.Atom(state.Data["mediaType"]),
+		Keywords:     state.Data["keywords"],
+		Title:        state.Data["title"],
+		Caption:      state.Data["caption"],
+		AltText:      state.Data["altText"],
+	}
+}
+
+// gifDetailPrompt returns the text asking for step i of gifDetailSteps. When editing a gif, the current value is shown.
+func gifDetailPrompt(state ConversationState, i int) string {
+	step := gifDetailSteps[i]
+	if state.Data["edit"] != "true" {
+		return fmt.Sprintf("Now send me %s, or /skip to leave it out.", step.prompt)
+	}
+
+	current := state.Data[step.key]
+	if current == "" {
+		return fmt.Sprintf("Now send me %s, or /skip to keep it as it is.", step.prompt)
+	}
+
+	return fmt.Sprintf("Now send me %s, /skip to keep it as it is or /none to clear it. It is currently: %s", step.prompt, current)
+}
+
+// gifDetailsTransducer handles each of gifDetailSteps in turn, saving the details of the gif once the last one is done.
+func gifDetailsTransducer(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, state ConversationState) (ConversationState, func() error, error) {
+	userID := message.From.ID
+
+	step := 0
+	for i := range gifDetailSteps {
+		if gifDetailSteps[i].state == state.State {
+			step = i
+		}
+	}
+
+	var text string
+	var nextState ConversationState
+	if message.Text != "" {
+		switch message.Command() {
+		case "skip":
+		case "none":
+			state.Data[gifDetailSteps[step].key] = ""
+		default:
+			state.Data[gifDetailSteps[step].key] = message.Text
+		}
+
+		if step+1 < len(gifDetailSteps) {
+			text = gifDetailPrompt(state, step+1)
+			nextState = ConversationState{
+				State: gifDetailSteps[step+1].state,
+				Data:  state.Data,
+			}
+		} else {
+			var err error
+			text, err = saveGifFromState(ctx, userID, state)
+			if err != nil {
+				return state, nil, err
+			}
+			nextState = ConversationState{}
+		}
+	} else {
+		text = "Oops, I was waiting for you to send me " + gifDetailSteps[step].prompt + ", or /skip."
+		nextState = state
+	}
+
+	chatID := message.Chat.ID
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+
+		if nextState.State != stateNone {
+			reply.ReplyMarkup = tgbotapi.ForceReply{
+				ForceReply: true,
+				Selective:  true,
+			}
+		}
+	}
+
+	action := func() error {
+		_, err := bot.Send(reply)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	return nextState, action, nil
+}
+
+// saveGifFromState updates the gif in state once all of its details have been collected, returning the text to reply
+// with. New gifs have already been added by the time their details are asked for.
+func saveGifFromState(ctx context.Context, userID int, state ConversationState) (string, error) {
+	var ok bool
+	var err error
+	if state.Data["saved"] == "true" {
+		ok, err = AddGifDetails(ctx, state.Data["packName"], userID, gifFromState(state))
+	} else {
+		ok, err = EditGif(ctx, state.Data["packName"], userID, gifFromState(state))
+	}
+	if err != nil {
+		switch err {
+		case ErrNotFound, ErrDeleted:
+			return "Whoops, that gif pack has been deleted.", nil
+		case ErrNotAllowed:
+			return "Oops, it seems like you are not allowed to edit gifs in this pack.", nil
+		default:
+			return "", err
+		}
+	}
+
+	if !ok {
+		return "Whoops, that gif is no longer in this pack.", nil
+	}
+
+	if state.Data["saved"] == "true" {
+		return "Great! The details of that gif have been saved.", nil
+	}
+
+	return "Great! That gif has been updated.", nil
+}
+
+// addGifFromState adds the gif in state to its pack as soon as it has keywords, returning the text to reply with and
+// true if it was added.
+func addGifFromState(ctx context.Context, userID int, state ConversationState) (string, bool, error) {
+	ok, err := NewGif(ctx, state.Data["packName"], userID, gifFromState(state))
+	if err != nil {
+		switch err {
+		case ErrNotFound, ErrDeleted:
+			return "Whoops, that gif pack has been deleted.", false, nil
+		case ErrNotAllowed:
+			return "Oops, it seems like you are not allowed to add gifs to this pack.", false, nil
+		default:
+			return "", false, err
+		}
+	}
+
+	if !ok {
+		return "Oops, that gif is already part of this pack. You can use /editgif to edit it instead.", false, nil
+	}
+
+	return "Great! A new gif has been added to your gif pack.", true, nil
+}
+
+func editGifWaitPackNameTransducer(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, state ConversationState) (ConversationState, func() error, error) {
+	userID := message.From.ID
+
+	var text string
+	var nextState ConversationState
+	if packName := message.Text; packName != "" {
+		pack, err := GetPack(ctx, packName)
+		if err != nil {
+			switch err {
+			case ErrInvalidName:
+				text = "Oh no! That was not a valid pack name. A pack name can only contain letters, numbers, hyphens and underscores."
+			case ErrNotFound:
+				text = "Oops! There doesn't seem to be any gif pack with that name."
+			case ErrDeleted:
+				text = "Whoops, that gif pack has been deleted."
+			default:
+				return state, nil, err
+			}
+			nextState = state
+		} else if !HasEditPermissions(pack, userID) {
+			text = "Oops, it seems like you are not allowed to edit gifs in this pack."
+			nextState = state
+		} else {
+			text = "Please send me the gif you want to edit."
+			state.State = stateEditGifWaitGif
+			state.Data["packName"] = pack.Name
+			nextState = state
+		}
+	} else {
+		text = "Oops, I was waiting for you to send me the name of the gif pack you want to edit a gif in."
+		nextState = state
+	}
+
+	chatID := message.Chat.ID
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+		reply.ReplyMarkup = tgbotapi.ForceReply{
+			ForceReply: true,
+			Selective:  true,
+		}
+	}
+
+	err := setPackNamesKeyboard(&reply, message, nextState.State == stateEditGifWaitPackName, nextState, func() ([]string, error) {
+		return userPackNames(ctx, userID, ActionEditGif)
+	})
+	if err != nil {
+		return state, nil, err
+	}
+
+	action := func() error {
+		_, err := bot.Send(reply)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	return nextState, action, nil
+}
+
+func editGifWaitGifTransducer(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, state ConversationState) (ConversationState, func() error, error) {
+	var text string
+	var nextState ConversationState
+	if fileID, _, ok := messageMedia(message); ok {
+		gif, err := GetGif(ctx, state.Data["packName"], mediaID(ctx, fileID))
+		if err != nil {
+			if err != ErrNotFound {
+				return state, nil, err
+			}
+
+			text = "Oops, I couldn't find that gif in this pack. Did you send the right one?"
+			nextState = state
+		} else {
+			nextState = editGifState(state.Data["packName"], gif)
+			text = editGifPrompt(gif)
+		}
+	} else {
+		text = "Oops, I was waiting for you to send me the gif you want to edit."
+		nextState = state
+	}
+
+	chatID := message.Chat.ID
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+		reply.ReplyMarkup = tgbotapi.ForceReply{
+			ForceReply: true,
+			Selective:  true,
+		}
+	}
+
+	action := func() error {
+		_, err := bot.Send(reply)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	return nextState, action, nil
+}
+
+// editGifState returns the conversation state for editing gif in pack, starting with its keywords.
+func editGifState(packName string, gif Gif) ConversationState {
+	return ConversationState{
+		State: stateEditGifWaitKeywords,
+		Data: map[string]string{
+			"edit":         "true",
+			"packName":     packName,
+			"fileID":       string(gif.FileID),
+			"fileUniqueID": string(gif.FileUniqueID),
+			"mediaType":    string(gif.Type),
+			"keywords":     gif.Keywords,
+			"title":        gif.Title,
+			"caption":      gif.Caption,
+			"altText":      gif.AltText,
+		},
+	}
+}
+
+// editGifPrompt returns the text asking for the new keywords of gif.
+func editGifPrompt(gif Gif) string {
+	return fmt.Sprintf("Please send me the new keywords for this gif, or /skip to keep them as they are. They are currently: %s", gif.Keywords)
+}
+
+func editGifWaitKeywordsTransducer(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, state ConversationState) (ConversationState, func() error, error) {
+	var text string
+	var nextState ConversationState
+	if message.Text != "" {
+		if message.Command() != "skip" {
+			state.Data["keywords"] = message.Text
+		}
+
+		text = gifDetailPrompt(state, 0)
+		nextState = ConversationState{
+			State: gifDetailSteps[0].state,
+			Data:  state.Data,
+		}
+	} else {
+		text = "Oops, I was waiting for you to send me the new keywords for this gif, or /skip."
+		nextState = state
+	}
+
+	chatID := message.Chat.ID
+	reply := tgbotapi.NewMessage(chatID, text)
+	if !message.Chat.IsPrivate() {
+		reply.ReplyToMessageID = message.MessageID
+		reply.ReplyMarkup = tgbotapi.ForceReply{
+			ForceReply: true,
+			Selective:  true,
+		}
+	}
+
+	action := func() error {
+		_, err := bot.Send(reply)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	return nextState, action, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchSummary(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nothing added", func(t *testing.T) {
+		state := ConversationState{
+			State: stateNewGifWaitGif,
+			Data:  map[string]string{"packName": "FOO", "batch": "true"},
+		}
+		assert.Equal(t, batchSummary(state), "All done! 0 gifs were added to FOO.")
+	})
+
+	t.Run("no pack chosen", func(t *testing.T) {
+		state := ConversationState{
+			State: stateNewGifWaitPackName,
+			Data:  map[string]string{"batch": "true"},
+		}
+		assert.Equal(t, batchSummary(state), "Command cancelled!")
+	})
+
+	t.Run("added and duplicates", func(t *testing.T) {
+		state := ConversationState{
+			State: stateNewGifWaitGif,
+			Data:  map[string]string{"packName": "FOO", "batch": "true", "added": "3", "duplicates": "1"},
+		}
+		assert.Equal(t, batchSummary(state), "All done! 3 gifs were added to FOO. 1 gif was already in the pack and skipped.")
+	})
+
+	t.Run("waiting for keywords", func(t *testing.T) {
+		state := ConversationState{
+			State: stateNewGifWaitKeywords,
+			Data:  map[string]string{"packName": "FOO", "batch": "true", "added": "1", "fileID": "bar"},
+		}
+		assert.Equal(t, batchSummary(state), "All done! 1 gif was added to FOO. The last gif was not added because it had no keywords.")
+	})
+}
+
+func TestIncrementCount(t *testing.T) {
+	t.Parallel()
+
+	data := make(map[string]string)
+	incrementCount(data, "added")
+	incrementCount(data, "added")
+	assert.Equal(t, data["added"], "2")
+}
+
+func TestParseQuickAddCaption(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		packName, keywords, ok := parseQuickAddCaption("cats: funny cat ")
+		assert.Equal(t, ok, true)
+		assert.Equal(t, packName, "cats")
+		assert.Equal(t, keywords, "funny cat")
+	})
+
+	t.Run("no pack name", func(t *testing.T) {
+		_, _, ok := parseQuickAddCaption("funny cat")
+		assert.Equal(t, ok, false)
+	})
+
+	t.Run("no keywords", func(t *testing.T) {
+		_, _, ok := parseQuickAddCaption("cats:")
+		assert.Equal(t, ok, false)
+	})
+
+	t.Run("sentence", func(t *testing.T) {
+		_, _, ok := parseQuickAddCaption("look at this: a cat")
+		assert.Equal(t, ok, false)
+	})
+}
+
+func TestGifDetailPrompt(t *testing.T) {
+	t.Parallel()
+
+	t.Run("new gif", func(t *testing.T) {
+		state := ConversationState{Data: map[string]string{"title": "Cat"}}
+		assert.Equal(t, gifDetailPrompt(state, 0), "Now send me a title for this gif, or /skip to leave it out.")
+	})
+
+	t.Run("edit gif", func(t *testing.T) {
+		state := ConversationState{Data: map[string]string{"edit": "true", "title": "Cat"}}
+		assert.Equal(t, gifDetailPrompt(state, 0), "Now send me a title for this gif, /skip to keep it as it is or /none to clear it. It is currently: Cat")
+	})
+
+	t.Run("edit gif without a title", func(t *testing.T) {
+		state := ConversationState{Data: map[string]string{"edit": "true"}}
+		assert.Equal(t, gifDetailPrompt(state, 0), "Now send me a title for this gif, or /skip to keep it as it is.")
+	})
+}
+
+func TestEditGifState(t *testing.T) {
+	t.Parallel()
+
+	gif := Gif{
+		Pack:     "FOO",
+		FileID:   "a",
+		Type:     MediaTypeGif,
+		Keywords: "funny cat",
+		Title:    "Cat",
+		Caption:  "lol",
+		AltText:  "a cat falling over",
+	}
+	state := editGifState("FOO", gif)
+	assert.Equal(t, state.State, stateEditGifWaitKeywords)
+	assert.Equal(t, gifFromState(state), gif)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strings"
+)
+
+// stem reduces an English word to its stem so that different forms of a word match each other, like "dance",
+// "dances", "danced" and "dancing" all becoming "danc". It implements the inflectional steps of the Porter stemmer
+// (steps 1 and 5), which handle plurals and verb endings but leave derived words like "happiness" alone. Words which
+// are not made up of lowercase ASCII letters are returned unchanged.
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+
+	for _, r := range word {
+		if r < 'a' || r > 'z' {
+			return word
+		}
+	}
+
+	word = stemPlural(word)
+	word = stemVerb(word)
+
+	// step 1c: turn a final y into i if there is another vowel, so "happy" and "happiest" agree
+	if strings.HasSuffix(word, "y") && hasVowel(word[:len(word)-1]) {
+		word = word[:len(word)-1] + "i"
+	}
+
+	return stemFinal(word)
+}
+
+// stemPlural removes plural endings (Porter step 1a).
+func stemPlural(word string) string {
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ies"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ss"):
+		return word
+	case strings.HasSuffix(word, "s"):
+		return word[:len(word)-1]
+	}
+
+	return word
+}
+
+// stemVerb removes past tense and progressive endings (Porter step 1b).
+func stemVerb(word string) string {
+	if strings.HasSuffix(word, "eed") {
+		if measure(word[:len(word)-3]) > 0 {
+			return word[:len(word)-1]
+		}
+
+		return word
+	}
+
+	var base string
+	switch {
+	case strings.HasSuffix(word, "ed"):
+		base = word[:len(word)-2]
+	case strings.HasSuffix(word, "ing"):
+		base = word[:len(word)-3]
+	default:
+		return word
+	}
+
+	if !hasVowel(base) {
+		return word
+	}
+
+	// put back letters which the ending replaced, so "hoping" becomes "hope" and "running" becomes "run"
+	switch {
+	case strings.HasSuffix(base, "at"), strings.HasSuffix(base, "bl"), strings.HasSuffix(base, "iz"):
+		return base + "e"
+	case endsWithDoubleConsonant(base) && !strings.ContainsAny(base[len(base)-1:], "lsz"):
+		return base[:len(base)-1]
+	case measure(base) == 1 && endsWithCVC(base):
+		return base + "e"
+	}
+
+	return base
+}
+
+// stemFinal removes a final e and doubled l (Porter step 5).
+func stemFinal(word string) string {
+	if strings.HasSuffix(word, "e") {
+		base := word[:len(word)-1]
+		if m := measure(base); m > 1 || m == 1 && !endsWithCVC(base) {
+			word = base
+		}
+	}
+
+	if measure(word) > 1 && strings.HasSuffix(word, "ll") {
+		word = word[:len(word)-1]
+	}
+
+	return word
+}
+
+// isConsonant returns true if the letter at i in word is a consonant. y is a consonant at the start of a word or after
+// a vowel.
+func isConsonant(word string, i int) bool {
+	switch word[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(word, i-1)
+	}
+
+	return true
+}
+
+// measure returns the number of vowel-consonant sequences in word, which is roughly its number of syllables.
+func measure(word string) int {
+	m := 0
+	vowel := false
+	for i := range word {
+		if isConsonant(word, i) {
+			if vowel {
+				m++
+			}
+			vowel = false
+		} else {
+			vowel = true
+		}
+	}
+
+	return m
+}
+
+func hasVowel(word string) bool {
+	for i := range word {
+		if !isConsonant(word, i) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func endsWithDoubleConsonant(word string) bool {
+	n := len(word)
+	return n >= 2 && word[n-1] == word[n-2] && isConsonant(word, n-1)
+}
+
+// endsWithCVC returns true if word ends with a consonant, vowel and consonant, where the last consonant is not w, x or
+// y, like "hop" but not "how".
+func endsWithCVC(word string) bool {
+	n := len(word)
+	if n < 3 {
+		return false
+	}
+
+	return isConsonant(word, n-3) && !isConsonant(word, n-2) && isConsonant(word, n-1) &&
+		!strings.ContainsAny(word[n-1:], "wxy")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStem(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]string{
+		"dance":    "danc",
+		"dances":   "danc",
+		"danced":   "danc",
+		"dancing":  "danc",
+		"cats":     "cat",
+		"caresses": "caress",
+		"ponies":   "poni",
+		"running":  "run",
+		"hoping":   "hope",
+		"hope":     "hope",
+		"agreed":   "agre",
+		"agree":    "agre",
+		"sing":     "sing",
+		"happy":    "happi",
+		"falling":  "fall",
+		"controll": "control",
+		"go":       "go",
+		"naïve":    "naïve",
+		"c3po":     "c3po",
+	}
+
+	for word, expected := range testCases {
+		assert.Equal(t, stem(word), expected, word)
+	}
+}
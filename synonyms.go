@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// maxSynonymGroups is the maximum number of synonym groups a pack can have, which keeps search queries short
+const maxSynonymGroups = 50
+
+// synonym errors
+var (
+	ErrTooFewSynonyms        = errors.New("too few synonyms")
+	ErrTooManySynonymGroups  = errors.New("too many synonym groups")
+	ErrSynonymGroupNotFound  = errors.New("synonym group not found")
+	ErrSynonymGroupDuplicate = errors.New("synonym group already exists")
+)
+
+// AddSynonyms adds a group of words which should find the same gifs when searching packName, like "lol haha laugh".
+// userID must be able to edit gifs in packName. err will be ErrTooFewSynonyms if words has fewer than two words.
+func AddSynonyms(ctx context.Context, packName string, userID int, words string) error {
+	group := synonymGroup(words)
+	if len(strings.Fields(group)) < 2 {
+		return ErrTooFewSynonyms
+	}
+
+	pack, err := GetPack(ctx, packName)
+	if err != nil {
+		return err
+	}
+
+	if !Can(pack, userID, ActionEditGif) {
+		return ErrNotAllowed
+	}
+
+	for _, existing := range pack.Synonyms {
+		if existing == group {
+			return ErrSynonymGroupDuplicate
+		}
+	}
+
+	if len(pack.Synonyms) >= maxSynonymGroups {
+		return ErrTooManySynonymGroups
+	}
+
+	pack.Synonyms = append(pack.Synonyms, group)
+	return SetPack(ctx, &pack)
+}
+
+// RemoveSynonyms removes the synonym group numbered n, counting from 1, from packName. userID must be able to edit gifs
+// in packName.
+func RemoveSynonyms(ctx context.Context, packName string, userID int, n int) error {
+	pack, err := GetPack(ctx, packName)
+	if err != nil {
+		return err
+	}
+
+	if !Can(pack, userID, ActionEditGif) {
+		return ErrNotAllowed
+	}
+
+	if n < 1 || n > len(pack.Synonyms) {
+		return ErrSynonymGroupNotFound
+	}
+
+	pack.Synonyms = append(pack.Synonyms[:n-1], pack.Synonyms[n:]...)
+	return SetPack(ctx, &pack)
+}
+
+// synonymGroup returns words lowercased and without repeats, in the form synonym groups are stored in.
+func synonymGroup(words string) string {
+	return mergeKeywords("", strings.ToLower(words))
+}
+
+// expandSynonyms returns terms followed by the normalised words of every synonym group which shares a word with terms.
+// terms must already be normalised with opts.
+func expandSynonyms(terms []string, groups []string, opts KeywordOptions) []string {
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		seen[term] = true
+	}
+
+	expanded := append([]string(nil), terms...)
+	for _, group := range groups {
+		words := normaliseKeywords(group, opts)
+
+		matched := false
+		for _, word := range words {
+			for _, term := range terms {
+				if word == term {
+					matched = true
+				}
+			}
+		}
+
+		if !matched {
+			continue
+		}
+
+		for _, word := range words {
+			if !seen[word] {
+				seen[word] = true
+				expanded = append(expanded, word)
+			}
+		}
+	}
+
+	return expanded
+}
+
+// synonymsMessage returns the text listing the synonym groups of pack.
+func synonymsMessage(pack Pack) string {
+	if len(pack.Synonyms) == 0 {
+		return fmt.Sprintf("%s doesn't have any synonyms yet. %s", pack.Name, synonymsUsage)
+	}
+
+	text := fmt.Sprintf("Here are the synonyms for %s:\n", pack.Name)
+	for i, group := range pack.Synonyms {
+		text += fmt.Sprintf("%d. %s\n", i+1, group)
+	}
+
+	return text
+}
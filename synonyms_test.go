@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSynonymGroup(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, synonymGroup("LOL haha  lol Laugh"), "lol haha laugh")
+}
+
+func TestExpandSynonyms(t *testing.T) {
+	t.Parallel()
+
+	opts := KeywordOptions{Stem: true, StopWords: true}
+	groups := []string{"lol haha laugh", "dog puppy"}
+
+	t.Run("no synonyms", func(t *testing.T) {
+		assert.Equal(t, expandSynonyms([]string{"cat"}, nil, opts), []string{"cat"})
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		assert.Equal(t, expandSynonyms([]string{"cat"}, groups, opts), []string{"cat"})
+	})
+
+	t.Run("match", func(t *testing.T) {
+		terms := normaliseKeywords("laughing cat", opts)
+		assert.Equal(t, expandSynonyms(terms, groups, opts), []string{"laugh", "cat", "lol", "haha"})
+	})
+
+	t.Run("terms are not changed", func(t *testing.T) {
+		terms := make([]string, 1, 10)
+		terms[0] = "puppy"
+		expandSynonyms(terms, groups, opts)
+		assert.Equal(t, terms[:2], []string{"puppy", ""})
+	})
+}
+
+func TestSynonymsMessage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no synonyms", func(t *testing.T) {
+		assert.Equal(t, synonymsMessage(Pack{Name: "PACK"}), "PACK doesn't have any synonyms yet. "+synonymsUsage)
+	})
+
+	t.Run("synonyms", func(t *testing.T) {
+		pack := Pack{Name: "PACK", Synonyms: []string{"lol haha", "dog puppy"}}
+		assert.Equal(t, synonymsMessage(pack), "Here are the synonyms for PACK:\n1. lol haha\n2. dog puppy\n")
+	})
+}
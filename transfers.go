@@ -0,0 +1,185 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// app engine datastore kinds
+const (
+	transferKind = "OwnershipTransfer"
+)
+
+// transfer errors
+var (
+	ErrNotContributor = errors.New("not a contributor")
+	ErrNoTransfer     = errors.New("no pending transfer")
+)
+
+// OwnershipTransfer represents a pending transfer of a gif pack from its owner to one of its contributors in
+// datastore. A pack can have at most one pending transfer.
+type OwnershipTransfer struct {
+	Pack    string
+	From    int
+	To      int
+	Created time.Time
+}
+
+// RequestTransfer asks newOwner to take over ownership of pack from owner. newOwner must already be a contributor to
+// pack, and the transfer only happens once they accept it. Any earlier pending transfer of pack is replaced.
+func RequestTransfer(ctx context.Context, packName string, owner, newOwner int) error {
+	// validate pack name
+	if !packNameRegex.MatchString(packName) {
+		return ErrInvalidName
+	}
+
+	// normalise pack name
+	packName = strings.ToUpper(packName)
+
+	pack, err := GetPack(ctx, packName)
+	if err != nil {
+		return err
+	}
+
+	if !Can(pack, owner, ActionManageMembers) {
+		return ErrNotAllowed
+	}
+
+	if PackRole(pack, newOwner) != RoleEditor {
+		return ErrNotContributor
+	}
+
+	transfer := OwnershipTransfer{
+		Pack:    packName,
+		From:    owner,
+		To:      newOwner,
+		Created: time.Now(),
+	}
+
+	key := datastore.NewKey(ctx, transferKind, packName, 0, nil)
+	_, err = datastore.Put(ctx, key, &transfer)
+	if err != nil {
+		return err
+	}
+
+	return addAuditEntry(ctx, packName, auditTransferRequested, owner, newOwner)
+}
+
+// GetPendingTransfers returns the transfers waiting for userID to accept them.
+func GetPendingTransfers(ctx context.Context, userID int) ([]OwnershipTransfer, error) {
+	var transfers []OwnershipTransfer
+	q := datastore.NewQuery(transferKind).Filter("To =", userID)
+	_, err := q.GetAll(ctx, &transfers)
+	if err != nil {
+		return nil, err
+	}
+
+	return transfers, nil
+}
+
+// AcceptTransfer makes userID the owner of pack if there is a pending transfer of pack to userID. The previous owner
+// becomes a contributor to pack. err will be ErrNoTransfer if there is no such transfer.
+func AcceptTransfer(ctx context.Context, packName string, userID int) error {
+	// validate pack name
+	if !packNameRegex.MatchString(packName) {
+		return ErrInvalidName
+	}
+
+	// normalise pack name
+	packName = strings.ToUpper(packName)
+
+	stale := false
+	err := datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		key := datastore.NewKey(tc, transferKind, packName, 0, nil)
+		var transfer OwnershipTransfer
+		err := datastore.Get(tc, key, &transfer)
+		if err != nil {
+			if err == datastore.ErrNoSuchEntity {
+				return ErrNoTransfer
+			}
+
+			return err
+		}
+
+		if transfer.To != userID {
+			return ErrNoTransfer
+		}
+
+		pack, err := GetPack(tc, packName)
+		if err != nil {
+			return err
+		}
+
+		// the pack may have changed hands since the transfer was requested, in which case the transfer can never be
+		// accepted and is removed. Returning an error here would roll the removal back.
+		if pack.Creator != transfer.From {
+			stale = true
+			return datastore.Delete(tc, key)
+		}
+
+		pack.Contributors, _ = removeUser(pack.Contributors, userID)
+		pack.Adders, _ = removeUser(pack.Adders, userID)
+		pack.Viewers, _ = removeUser(pack.Viewers, userID)
+		pack.Contributors = append(pack.Contributors, pack.Creator)
+		pack.Creator = userID
+
+		err = SetPack(tc, &pack)
+		if err != nil {
+			return err
+		}
+
+		err = datastore.Delete(tc, key)
+		if err != nil {
+			return err
+		}
+
+		return addAuditEntry(tc, packName, auditTransferAccepted, userID, transfer.From)
+	}, &datastore.TransactionOptions{XG: true})
+	if err != nil {
+		return err
+	}
+
+	if stale {
+		return ErrNoTransfer
+	}
+
+	return nil
+}
+
+// CancelTransfer removes the pending transfer of pack. Either the owner who requested the transfer or the contributor
+// it was requested to can cancel it. err will be ErrNoTransfer if there is no pending transfer involving userID.
+func CancelTransfer(ctx context.Context, packName string, userID int) error {
+	// validate pack name
+	if !packNameRegex.MatchString(packName) {
+		return ErrInvalidName
+	}
+
+	// normalise pack name
+	packName = strings.ToUpper(packName)
+
+	key := datastore.NewKey(ctx, transferKind, packName, 0, nil)
+	var transfer OwnershipTransfer
+	err := datastore.Get(ctx, key, &transfer)
+	if err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return ErrNoTransfer
+		}
+
+		return err
+	}
+
+	if userID != transfer.From && userID != transfer.To {
+		return ErrNoTransfer
+	}
+
+	err = datastore.Delete(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	return addAuditEntry(ctx, packName, auditTransferCancelled, userID, 0)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/appengine/aetest"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestRequestTransfer(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	pack1 := Pack{
+		Name:         "pack1",
+		Creator:      1,
+		Contributors: []int{2},
+		Viewers:      []int{3},
+	}
+
+	key := datastore.NewKey(ctx, packKind, strings.ToUpper(pack1.Name), 0, nil)
+	_, err = datastore.Put(ctx, key, &pack1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("not owner", func(t *testing.T) {
+		err := RequestTransfer(ctx, pack1.Name, 2, 2)
+		assert.Equal(t, err, ErrNotAllowed)
+	})
+
+	t.Run("not contributor", func(t *testing.T) {
+		err := RequestTransfer(ctx, pack1.Name, 1, 3)
+		assert.Equal(t, err, ErrNotContributor)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		err := RequestTransfer(ctx, pack1.Name, 1, 2)
+		assert.Equal(t, err, nil)
+
+		// ownership only changes once the transfer is accepted
+		pack, err := GetPack(ctx, pack1.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, pack.Creator, 1)
+	})
+}
+
+func TestAcceptTransfer(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := NewContext(&aetest.Options{
+		StronglyConsistentDatastore: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	pack1 := Pack{
+		Name:         "pack1",
+		Creator:      1,
+		Contributors: []int{2, 3},
+	}
+
+	key := datastore.NewKey(ctx, packKind, strings.ToUpper(pack1.Name), 0, nil)
+	_, err = datastore.Put(ctx, key, &pack1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RequestTransfer(ctx, pack1.Name, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("not new owner", func(t *testing.T) {
+		err := AcceptTransfer(ctx, pack1.Name, 3)
+		assert.Equal(t, err, ErrNoTransfer)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		err := AcceptTransfer(ctx, pack1.Name, 2)
+		assert.Equal(t, err, nil)
+
+		pack, err := GetPack(ctx, pack1.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, pack.Creator, 2)
+		assert.True(t, sliceSameContents([]int{1, 3}, pack.Contributors))
+
+		// the new owner can now manage the pack
+		err = SoftDeletePack(ctx, pack1.Name, 1)
+		assert.Equal(t, err, ErrNotAllowed)
+
+		entries, err := GetAuditTrail(ctx, pack1.Name, 2)
+		assert.Equal(t, err, nil)
+		if assert.Len(t, entries, 2) {
+			assert.Equal(t, entries[0].Action, auditTransferRequested)
+			assert.Equal(t, entries[1].Action, auditTransferAccepted)
+			assert.Equal(t, entries[1].Actor, 2)
+			assert.Equal(t, entries[1].Subject, 1)
+		}
+	})
+
+	t.Run("already accepted", func(t *testing.T) {
+		err := AcceptTransfer(ctx, pack1.Name, 2)
+		assert.Equal(t, err, ErrNoTransfer)
+	})
+
+	t.Run("owner changed", func(t *testing.T) {
+		pack2 := Pack{
+			Name:         "pack2",
+			Creator:      1,
+			Contributors: []int{2, 3},
+		}
+
+		key := datastore.NewKey(ctx, packKind, strings.ToUpper(pack2.Name), 0, nil)
+		_, err := datastore.Put(ctx, key, &pack2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = RequestTransfer(ctx, pack2.Name, 1, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pack2.Creator = 3
+		_, err = datastore.Put(ctx, key, &pack2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = AcceptTransfer(ctx, pack2.Name, 2)
+		assert.Equal(t, err, ErrNoTransfer)
+
+		// the stale transfer is removed
+		var transfer OwnershipTransfer
+		err = datastore.Get(ctx, datastore.NewKey(ctx, transferKind, strings.ToUpper(pack2.Name), 0, nil), &transfer)
+		assert.Equal(t, err, datastore.ErrNoSuchEntity)
+	})
+}
+
+func TestCancelTransfer(t *testing.T) {
+	t.Parallel()
+
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	pack1 := Pack{
+		Name:         "pack1",
+		Creator:      1,
+		Contributors: []int{2},
+	}
+
+	key := datastore.NewKey(ctx, packKind, strings.ToUpper(pack1.Name), 0, nil)
+	_, err = datastore.Put(ctx, key, &pack1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RequestTransfer(ctx, pack1.Name, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("not involved", func(t *testing.T) {
+		err := CancelTransfer(ctx, pack1.Name, 3)
+		assert.Equal(t, err, ErrNoTransfer)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		err := CancelTransfer(ctx, pack1.Name, 2)
+		assert.Equal(t, err, nil)
+
+		err = AcceptTransfer(ctx, pack1.Name, 2)
+		assert.Equal(t, err, ErrNoTransfer)
+	})
+}